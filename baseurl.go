@@ -0,0 +1,101 @@
+package imgproxy
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// BaseURLSelector selects the base URL of a source among the configured BaseURLs.
+// Signatures only cover the path, so any base URL can serve any generated URL.
+type BaseURLSelector interface {
+	// SelectBaseURL returns the index of the base URL to use for the source, between 0 and n-1.
+	SelectBaseURL(source string, n int) int
+}
+
+// HashSelector selects the base URL from a hash of the source,
+// so the same source always maps to the same base URL. It is the default selector.
+type HashSelector struct{}
+
+// SelectBaseURL returns the index of the base URL for the source.
+func (HashSelector) SelectBaseURL(source string, n int) int {
+	return int(hashSource(source) % uint32(n))
+}
+
+// RoundRobinSelector cycles through the base URLs.
+// Unlike HashSelector, the same source maps to different base URLs, which defeats browser caches.
+type RoundRobinSelector struct {
+	next uint32
+}
+
+// SelectBaseURL returns the index of the next base URL.
+func (r *RoundRobinSelector) SelectBaseURL(_ string, n int) int {
+	return int((atomic.AddUint32(&r.next, 1) - 1) % uint32(n))
+}
+
+// WeightedSelector selects the base URL from a hash of the source, in proportion to the weights.
+// Weights holds one weight per base URL, in the same order. When they don't match the base URLs,
+// it falls back to HashSelector.
+type WeightedSelector struct {
+	Weights []uint32
+}
+
+// SelectBaseURL returns the index of the base URL for the source.
+func (w WeightedSelector) SelectBaseURL(source string, n int) int {
+	var total uint32
+	for _, weight := range w.Weights {
+		total += weight
+	}
+
+	if len(w.Weights) != n || total == 0 {
+		return HashSelector{}.SelectBaseURL(source, n)
+	}
+
+	point := hashSource(source) % total
+	for j, weight := range w.Weights {
+		if point < weight {
+			return j
+		}
+		point -= weight
+	}
+
+	return n - 1
+}
+
+// hashSource returns the 32-bit FNV-1a hash of the source.
+func hashSource(source string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	hash := uint32(offset32)
+	for j := 0; j < len(source); j++ {
+		hash ^= uint32(source[j])
+		hash *= prime32
+	}
+
+	return hash
+}
+
+// baseURL returns the base URL of the source.
+func (i *Imgproxy) baseURL(source string) string {
+	if len(i.baseURLs) == 1 {
+		return i.baseURLs[0]
+	}
+
+	j := i.cfg.BaseURLSelector.SelectBaseURL(source, len(i.baseURLs))
+	if j < 0 || j >= len(i.baseURLs) {
+		j = HashSelector{}.SelectBaseURL(source, len(i.baseURLs))
+	}
+
+	return i.baseURLs[j]
+}
+
+// normalizeBaseURL adds the trailing slash of a base URL.
+func normalizeBaseURL(baseURL string) string {
+	if !strings.HasSuffix(baseURL, "/") {
+		return baseURL + "/"
+	}
+
+	return baseURL
+}
@@ -0,0 +1,138 @@
+package imgproxy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// GenerateBatch generates the imgproxy URLs of the sources, all with the same options.
+// The options are serialized once for the whole batch.
+func (i *ImgproxyURLData) GenerateBatch(sources []string) ([]string, error) {
+	if i.err != nil {
+		return nil, i.err
+	}
+
+	optionsPath := i.appendOptionsPath(nil)
+	urls := make([]string, len(sources))
+	var buf []byte
+
+	for j, source := range sources {
+		var err error
+		if buf, err = i.appendBatchURL(context.Background(), buf[:0], optionsPath, source); err != nil {
+			return nil, errors.Wrapf(err, "source %d", j)
+		}
+
+		urls[j] = string(buf)
+	}
+
+	return urls, nil
+}
+
+// appendBatchURL is the same as AppendURL with the options already serialized.
+func (i *ImgproxyURLData) appendBatchURL(ctx context.Context, dst []byte, optionsPath []byte, uri string) ([]byte, error) {
+	start := len(dst)
+	dst, signatureAt := i.appendURLStart(append(dst, i.baseURL(uri)...))
+	dst = append(dst, optionsPath...)
+
+	return i.appendURLEnd(ctx, dst, start, signatureAt, uri)
+}
+
+// BatchItem holds a source to generate an URL for, with the builder holding its options.
+type BatchItem struct {
+	Builder *ImgproxyURLData
+	Source  string
+}
+
+// BatchResult holds the URL generated for a BatchItem, or the error that prevented it.
+type BatchResult struct {
+	URL string
+	Err error
+}
+
+// GenerateItems generates the URLs of the items, serializing the options of each builder once.
+// Up to workers goroutines generate the URLs concurrently, a value lower than 2 generates them sequentially.
+// The results are in the same order as the items. Items that were not generated when ctx is done get its error,
+// and items without builder get an ErrInvalidOption error.
+// Builders must not be modified until GenerateItems returns.
+func GenerateItems(ctx context.Context, items []BatchItem, workers int) []BatchResult {
+	results := make([]BatchResult, len(items))
+	optionsPaths := make(map[*ImgproxyURLData][]byte)
+
+	for _, item := range items {
+		if item.Builder == nil {
+			continue
+		}
+
+		if _, ok := optionsPaths[item.Builder]; !ok && item.Builder.err == nil {
+			optionsPaths[item.Builder] = item.Builder.appendOptionsPath(nil)
+		}
+	}
+
+	generate := func(j int, buf []byte) []byte {
+		item := items[j]
+
+		if err := ctx.Err(); err != nil {
+			results[j].Err = err
+			return buf
+		}
+
+		if item.Builder == nil {
+			results[j].Err = errors.Wrapf(ErrInvalidOption, "batch item %d has no builder", j)
+			return buf
+		}
+
+		if item.Builder.err != nil {
+			results[j].Err = item.Builder.err
+			return buf
+		}
+
+		url, err := item.Builder.appendBatchURL(ctx, buf[:0], optionsPaths[item.Builder], item.Source)
+		if err != nil {
+			results[j].Err = err
+			return url
+		}
+
+		results[j].URL = string(url)
+
+		return url
+	}
+
+	if workers < 2 || len(items) < 2 {
+		var buf []byte
+		for j := range items {
+			buf = generate(j, buf)
+		}
+
+		return results
+	}
+
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			var buf []byte
+			for j := range indexes {
+				buf = generate(j, buf)
+			}
+		}()
+	}
+
+	for j := range items {
+		indexes <- j
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,204 @@
+package imgproxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxErrorMessageSize is the maximum number of bytes of an error response read into a ResponseError.
+const maxErrorMessageSize = 64 << 10
+
+// maxBackoff is the maximum delay of the default backoff.
+const maxBackoff = 10 * time.Second
+
+// ClientConfig holds the parameters of a Client.
+type ClientConfig struct {
+	// HTTPClient sends the requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// MaxRetries is the number of retries on network errors and 5xx responses. 0 disables retries.
+	MaxRetries int
+	// Backoff returns the delay before the given retry, starting at 1.
+	// Defaults to an exponential backoff starting at 100ms, up to 10s.
+	Backoff func(retry int) time.Duration
+}
+
+// Client fetches processed images and their metadata from imgproxy.
+type Client struct {
+	*Imgproxy
+	cfg ClientConfig
+}
+
+// ResponseError is returned when imgproxy responds with an error status.
+type ResponseError struct {
+	StatusCode int
+	// Message holds the response body, which imgproxy fills with the error details.
+	Message string
+	URL     string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("imgproxy: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// FetchResult holds a processed image. Body must be closed by the caller.
+type FetchResult struct {
+	Body          io.ReadCloser
+	ContentType   string
+	ContentLength int64
+	// Width and Height of the resulting image, and of the source image, from the imgproxy debug headers.
+	// They are 0 when IMGPROXY_ENABLE_DEBUG_HEADERS is not enabled.
+	Width        int
+	Height       int
+	OriginWidth  int
+	OriginHeight int
+	Header       http.Header
+}
+
+// NewClient returns a new *Client.
+func NewClient(ip *Imgproxy, cfg ClientConfig) *Client {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	if cfg.Backoff == nil {
+		cfg.Backoff = exponentialBackoff
+	}
+
+	return &Client{
+		Imgproxy: ip,
+		cfg:      cfg,
+	}
+}
+
+func exponentialBackoff(retry int) time.Duration {
+	// 100ms << 7 is over maxBackoff, larger shifts would overflow.
+	if retry > 7 {
+		return maxBackoff
+	}
+
+	if backoff := 100 * time.Millisecond << (retry - 1); backoff < maxBackoff {
+		return backoff
+	}
+
+	return maxBackoff
+}
+
+// Fetch fetches the source image processed with the builder options.
+func (c *Client) Fetch(ctx context.Context, builder *ImgproxyURLData, source string) (*FetchResult, error) {
+	url, err := builder.GenerateContext(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FetchResult{
+		Body:          res.Body,
+		ContentType:   res.Header.Get("Content-Type"),
+		ContentLength: res.ContentLength,
+		Width:         headerInt(res.Header, "X-Result-Width"),
+		Height:        headerInt(res.Header, "X-Result-Height"),
+		OriginWidth:   headerInt(res.Header, "X-Origin-Width"),
+		OriginHeight:  headerInt(res.Header, "X-Origin-Height"),
+		Header:        res.Header,
+	}, nil
+}
+
+// Info fetches the metadata of the source image from the imgproxy Pro info endpoint.
+func (c *Client) Info(ctx context.Context, builder *InfoURLData, source string) (*Info, error) {
+	url, err := builder.GenerateContext(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return DecodeInfo(res.Body)
+}
+
+// get sends a GET request, retrying on network errors and 5xx responses.
+// Error responses are returned as *ResponseError.
+func (c *Client) get(ctx context.Context, url string) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, errors.WithStack(err)
+			}
+
+			timer := time.NewTimer(c.cfg.Backoff(retry))
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, errors.WithStack(ctx.Err())
+			case <-timer.C:
+			}
+		}
+
+		res, err := c.do(ctx, url)
+		if err == nil {
+			return res, nil
+		}
+
+		if retry >= c.cfg.MaxRetries || !isRetryable(err) {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	res, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+
+	defer res.Body.Close()
+
+	message, err := io.ReadAll(io.LimitReader(res.Body, maxErrorMessageSize))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return nil, errors.WithStack(&ResponseError{
+		StatusCode: res.StatusCode,
+		Message:    string(message),
+		URL:        url,
+	})
+}
+
+// isRetryable returns whether the error is a network error or a 5xx response.
+func isRetryable(err error) bool {
+	var responseErr *ResponseError
+	if errors.As(err, &responseErr) {
+		return responseErr.StatusCode >= 500
+	}
+
+	return true
+}
+
+func headerInt(header http.Header, key string) int {
+	n, _ := strconv.Atoi(header.Get(key))
+	return n
+}
@@ -0,0 +1,223 @@
+package imgproxy
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Client(t *testing.T) {
+	Convey("Client", t, func() {
+		var requests int32
+		var path atomic.Value
+		var handler http.HandlerFunc
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			path.Store(r.URL.Path)
+			handler(w, r)
+		}))
+		defer server.Close()
+
+		ip, err := NewImgproxy(Config{
+			BaseURL:       server.URL,
+			SignatureSize: 15,
+			Key:           hex.EncodeToString([]byte("key")),
+			Salt:          hex.EncodeToString([]byte("salt")),
+			EncodePath:    false,
+		})
+		So(err, ShouldBeNil)
+
+		client := NewClient(ip, ClientConfig{
+			HTTPClient: server.Client(),
+			MaxRetries: 2,
+			Backoff: func(int) time.Duration {
+				return time.Millisecond
+			},
+		})
+
+		Convey("Fetch returns the image and its dimensions", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				w.Header().Set("X-Result-Width", "1")
+				w.Header().Set("X-Result-Height", "2")
+				w.Header().Set("X-Origin-Width", "10")
+				w.Header().Set("X-Origin-Height", "20")
+				io.WriteString(w, "png")
+			}
+
+			res, err := client.Fetch(context.Background(), client.Builder().Width(1), "my/image.jpg")
+			So(err, ShouldBeNil)
+			defer res.Body.Close()
+			So(path.Load(), ShouldEqual, "/196LdHe9OIT7BZBGvnHF/w:1/plain/my/image.jpg")
+
+			body, err := io.ReadAll(res.Body)
+			So(err, ShouldBeNil)
+			So(string(body), ShouldEqual, "png")
+			So(res.ContentType, ShouldEqual, "image/png")
+			So(res.Width, ShouldEqual, 1)
+			So(res.Height, ShouldEqual, 2)
+			So(res.OriginWidth, ShouldEqual, 10)
+			So(res.OriginHeight, ShouldEqual, 20)
+		})
+
+		Convey("Fetch retries on 5xx responses", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				if atomic.LoadInt32(&requests) < 3 {
+					http.Error(w, "overloaded", http.StatusServiceUnavailable)
+					return
+				}
+
+				io.WriteString(w, "png")
+			}
+
+			res, err := client.Fetch(context.Background(), client.Builder(), "my/image.jpg")
+			So(err, ShouldBeNil)
+			res.Body.Close()
+			So(atomic.LoadInt32(&requests), ShouldEqual, 3)
+		})
+
+		Convey("Fetch returns the imgproxy error after the last retry", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			}
+
+			_, err := client.Fetch(context.Background(), client.Builder(), "my/image.jpg")
+
+			var responseErr *ResponseError
+			So(errors.As(err, &responseErr), ShouldBeTrue)
+			So(responseErr.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+			So(responseErr.Message, ShouldEqual, "overloaded\n")
+			So(atomic.LoadInt32(&requests), ShouldEqual, 3)
+		})
+
+		Convey("Fetch doesn't retry on 4xx responses", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Invalid signature", http.StatusForbidden)
+			}
+
+			_, err := client.Fetch(context.Background(), client.Builder(), "my/image.jpg")
+
+			var responseErr *ResponseError
+			So(errors.As(err, &responseErr), ShouldBeTrue)
+			So(responseErr.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(atomic.LoadInt32(&requests), ShouldEqual, 1)
+		})
+
+		Convey("Fetch stops retrying when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				cancel()
+				http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			}
+
+			_, err := client.Fetch(ctx, client.Builder(), "my/image.jpg")
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+			So(atomic.LoadInt32(&requests), ShouldEqual, 1)
+		})
+
+		Convey("Info decodes the info response", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, `{"width": 300, "height": 200}`)
+			}
+
+			info, err := client.Info(context.Background(), client.InfoBuilder().Dimensions(true), "my/image.jpg")
+			So(err, ShouldBeNil)
+			So(path.Load(), ShouldStartWith, "/info/")
+			So(info.Width, ShouldEqual, 300)
+			So(info.Height, ShouldEqual, 200)
+		})
+
+		Convey("Health reads the version from the Server header", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Server", "imgproxy/3.21.0")
+				io.WriteString(w, "imgproxy is running")
+			}
+
+			health, err := client.Health(context.Background())
+			So(err, ShouldBeNil)
+			So(path.Load(), ShouldEqual, "/health")
+			So(health.Server, ShouldEqual, "imgproxy/3.21.0")
+			So(health.Version, ShouldEqual, "3.21.0")
+			So(health.AtLeast("3.21"), ShouldBeTrue)
+			So(health.AtLeast("3.9.1"), ShouldBeTrue)
+			So(health.AtLeast("3.21.1"), ShouldBeFalse)
+			So(health.AtLeast("4"), ShouldBeFalse)
+		})
+
+		Convey("Health has no version with the default Server header", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Server", "imgproxy")
+				io.WriteString(w, "imgproxy is running")
+			}
+
+			health, err := client.Health(context.Background())
+			So(err, ShouldBeNil)
+			So(health.Version, ShouldEqual, "")
+			So(health.AtLeast("1.0.0"), ShouldBeFalse)
+		})
+
+		Convey("CheckHealth fails on old or unknown versions", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Server", "imgproxy/2.17.0")
+				io.WriteString(w, "imgproxy is running")
+			}
+
+			So(client.CheckHealth(context.Background(), ""), ShouldBeNil)
+			So(client.CheckHealth(context.Background(), "2.16"), ShouldBeNil)
+			So(errors.Is(client.CheckHealth(context.Background(), "3.0.0"), ErrUnsupportedVersion), ShouldBeTrue)
+
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "imgproxy is running")
+			}
+
+			So(errors.Is(client.CheckHealth(context.Background(), "2.16"), ErrUnsupportedVersion), ShouldBeTrue)
+		})
+
+		Convey("ReadinessHandler responds with the health status", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Server", "imgproxy/3.21.0")
+				io.WriteString(w, "imgproxy is running")
+			}
+
+			recorder := httptest.NewRecorder()
+			client.ReadinessHandler("3.0.0").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "not ready", http.StatusServiceUnavailable)
+			}
+
+			recorder = httptest.NewRecorder()
+			client.ReadinessHandler("").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			So(recorder.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(recorder.Body.String(), ShouldContainSubstring, "not ready")
+		})
+	})
+}
+
+func Test_ExponentialBackoff(t *testing.T) {
+	Convey("exponentialBackoff()", t, func() {
+		Convey("Doubles the delay", func() {
+			So(exponentialBackoff(1), ShouldEqual, 100*time.Millisecond)
+			So(exponentialBackoff(2), ShouldEqual, 200*time.Millisecond)
+			So(exponentialBackoff(7), ShouldEqual, 6400*time.Millisecond)
+		})
+
+		Convey("Caps the delay", func() {
+			for _, retry := range []int{8, 30, 40, 64, 1000} {
+				So(exponentialBackoff(retry), ShouldEqual, maxBackoff)
+			}
+		})
+	})
+}
@@ -1,5 +1,7 @@
 package imgproxy
 
+import "time"
+
 // Config holds the parameters for constructing an imgproxy URL builder.
 type Config struct {
 	BaseURL       string
@@ -7,4 +9,34 @@ type Config struct {
 	Key           string
 	Salt          string
 	EncodePath    bool
+	// BaseURLs replaces BaseURL to spread the URLs over several hosts, e.g. CDN shards.
+	BaseURLs []string
+	// BaseURLSelector selects the base URL of each source among BaseURLs. Defaults to HashSelector.
+	BaseURLSelector BaseURLSelector
+	// Base64URLIncludesFilename must match the IMGPROXY_BASE64_URL_INCLUDES_FILENAME imgproxy setting.
+	// When set, base64 encoded sources are followed by the filename set with SourceFilename.
+	Base64URLIncludesFilename bool
+	// UnescapedPlainSources writes plain sources as they are, like previous versions did,
+	// instead of percent-encoding the characters imgproxy would misread.
+	UnescapedPlainSources bool
+	// Signer signs the URLs instead of the HMAC signer built from Key and Salt.
+	Signer Signer
+	// OptionOrder defines the order of the options in generated URLs. Defaults to OptionOrderSorted.
+	OptionOrder OptionOrder
+	// Clock returns the current time, used by relative options like ExpiresIn. Defaults to time.Now.
+	Clock func() time.Time
 }
+
+// OptionOrder defines the order of the options in generated URLs.
+// Different orders produce different signatures, so all services sharing a cache should use the same one.
+type OptionOrder int
+
+// OptionOrder constants.
+const (
+	// Options are sorted alphabetically by key.
+	OptionOrderSorted = OptionOrder(iota)
+	// Options keep the order in which they were first set.
+	OptionOrderInsertion
+	// Options follow the order in which imgproxy processes them, presets first.
+	OptionOrderCanonical
+)
@@ -0,0 +1,140 @@
+package imgproxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const healthPath = "health"
+
+// Health holds the result of an imgproxy health check.
+type Health struct {
+	// Server holds the Server response header, imgproxy by default, see IMGPROXY_SERVER_NAME.
+	Server string
+	// Version holds the imgproxy version read from the Server header when it is set as imgproxy/x.y.z.
+	// It is empty when the version is not available.
+	Version string
+}
+
+// AtLeast returns whether the imgproxy version is at least the minimum x.y.z version.
+// It returns false when the version is not available.
+func (h *Health) AtLeast(minVersion string) bool {
+	version, ok := parseVersion(h.Version)
+	if !ok {
+		return false
+	}
+
+	min, ok := parseVersion(minVersion)
+	if !ok {
+		return false
+	}
+
+	for j := range version {
+		if version[j] != min[j] {
+			return version[j] > min[j]
+		}
+	}
+
+	return true
+}
+
+// Health calls the imgproxy /health endpoint of the first base URL.
+// It returns an error when imgproxy is unreachable or unhealthy.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	res, err := c.get(ctx, c.baseURLs[0]+healthPath)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if _, err := io.Copy(io.Discard, res.Body); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	server := res.Header.Get("Server")
+
+	return &Health{
+		Server:  server,
+		Version: serverVersion(server),
+	}, nil
+}
+
+// CheckHealth checks that imgproxy is healthy and, unless minVersion is empty, that its version
+// is at least minVersion. It returns ErrUnsupportedVersion when the version is older or not available.
+func (c *Client) CheckHealth(ctx context.Context, minVersion string) error {
+	health, err := c.Health(ctx)
+	if err != nil {
+		return err
+	}
+
+	if minVersion != "" && !health.AtLeast(minVersion) {
+		version := health.Version
+		if version == "" {
+			version = "unknown"
+		}
+
+		return errors.Wrapf(ErrUnsupportedVersion, "imgproxy %s, want at least %s", version, minVersion)
+	}
+
+	return nil
+}
+
+// ReadinessHandler returns an http.Handler responding with 200 when imgproxy passes CheckHealth,
+// and with 503 and the error otherwise. It can be mounted as the readiness endpoint of a service.
+func (c *Client) ReadinessHandler(minVersion string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := c.CheckHealth(r.Context(), minVersion); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// serverVersion returns the version of an imgproxy/x.y.z Server header, or an empty string.
+func serverVersion(server string) string {
+	name, version, ok := strings.Cut(server, "/")
+	if !ok || !strings.EqualFold(name, "imgproxy") {
+		return ""
+	}
+
+	version = strings.TrimPrefix(version, "v")
+	if _, ok := parseVersion(version); !ok {
+		return ""
+	}
+
+	return version
+}
+
+// parseVersion parses a x.y.z version, where minor and patch are optional.
+// Pre-release and build suffixes are ignored.
+func parseVersion(version string) ([3]int, bool) {
+	var parsed [3]int
+
+	if i := strings.IndexAny(version, "-+ "); i >= 0 {
+		version = version[:i]
+	}
+
+	parts := strings.Split(version, ".")
+	if len(parts) > len(parsed) {
+		return parsed, false
+	}
+
+	for j, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return parsed, false
+		}
+
+		parsed[j] = n
+	}
+
+	return parsed, true
+}
@@ -3,25 +3,51 @@ package imgproxy
 import (
 	"encoding/hex"
 	stdErrs "errors"
-	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 // Imgproxy is a URL builder helper for imgproxy.
 type Imgproxy struct {
-	cfg  Config
-	key  []byte
-	salt []byte
+	cfg      Config
+	baseURLs []string
+	signer   Signer
 }
 
 // ErrInvalidSignature error.
 var ErrInvalidSignature = stdErrs.New("invalid signature size")
 
+// ErrInvalidURL error, returned when parsing a malformed imgproxy URL.
+var ErrInvalidURL = stdErrs.New("invalid imgproxy url")
+
+// ErrSignatureMismatch error, returned when parsing an imgproxy URL with a wrong signature.
+var ErrSignatureMismatch = stdErrs.New("signature mismatch")
+
+// ErrInvalidOption error, returned by Generate when an option was given an invalid value.
+var ErrInvalidOption = stdErrs.New("invalid option")
+
+// ErrUnsupportedVersion error, returned by CheckHealth when imgproxy is older than required.
+var ErrUnsupportedVersion = stdErrs.New("unsupported imgproxy version")
+
 // NewImgproxy returns a new *Imgproxy.
 func NewImgproxy(cfg Config) (*Imgproxy, error) {
-	if !strings.HasSuffix(cfg.BaseURL, "/") {
-		cfg.BaseURL = cfg.BaseURL + "/"
+	cfg.BaseURL = normalizeBaseURL(cfg.BaseURL)
+
+	baseURLs := []string{cfg.BaseURL}
+	if len(cfg.BaseURLs) > 0 {
+		baseURLs = make([]string, len(cfg.BaseURLs))
+		for j, baseURL := range cfg.BaseURLs {
+			baseURLs[j] = normalizeBaseURL(baseURL)
+		}
+	}
+
+	if cfg.BaseURLSelector == nil {
+		cfg.BaseURLSelector = HashSelector{}
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
 	}
 
 	if cfg.SignatureSize < 1 || cfg.SignatureSize > 32 {
@@ -38,17 +64,32 @@ func NewImgproxy(cfg Config) (*Imgproxy, error) {
 		return nil, errors.WithStack(err)
 	}
 
+	signer := cfg.Signer
+	if hmacSigner, ok := signer.(HMACSigner); ok {
+		key, salt = hmacSigner.Key, hmacSigner.Salt
+		signer = nil
+	}
+
+	if signer == nil && (len(key) > 0 || len(salt) > 0) {
+		if signer, err = newKeyedHMAC(key, salt); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Imgproxy{
-		cfg:  cfg,
-		salt: salt,
-		key:  key,
+		cfg:      cfg,
+		baseURLs: baseURLs,
+		signer:   signer,
 	}, nil
 }
 
 // Builder returns a *ImgproxyURLData that can be used to construct an imgproxy URL.
 func (i *Imgproxy) Builder() *ImgproxyURLData {
+	p := newPipeline()
+
 	return &ImgproxyURLData{
-		Imgproxy: i,
-		Options:  make(map[string]string, 0),
+		Imgproxy:  i,
+		Options:   p.options,
+		pipelines: []*pipeline{p},
 	}
 }
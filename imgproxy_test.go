@@ -1,8 +1,12 @@
 package imgproxy
 
 import (
+	"context"
 	"encoding/hex"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
@@ -55,6 +59,26 @@ func Test_ImgproxyBuilder(t *testing.T) {
 			So(url, ShouldEqual, "http://localhost/insecure/plain/my/image.jpg")
 		})
 
+		Convey("ExpiresIn uses the configured clock", func() {
+			ip, err := NewImgproxy(Config{
+				BaseURL:       "http://localhost",
+				SignatureSize: 15,
+				Key:           hex.EncodeToString([]byte("key")),
+				Salt:          hex.EncodeToString([]byte("salt")),
+				Clock: func() time.Time {
+					return time.Unix(1700000000, 0)
+				},
+			})
+			So(err, ShouldBeNil)
+
+			url, err := ip.Builder().
+				ExpiresIn(time.Hour).
+				Generate("my/image.jpg")
+
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/WOeSbyIqjzTXLV8HHfYn/exp:1700003600/plain/my/image.jpg")
+		})
+
 		Convey("With key salt and no encoded", func() {
 			ip, err := NewImgproxy(Config{
 				BaseURL:       "http://localhost",
@@ -218,6 +242,14 @@ func Test_ImgproxyBuilder(t *testing.T) {
 					So(err, ShouldBeNil)
 					So(url, ShouldEqual, "http://localhost/cZhqkP4TlQRjki_sH00q/bg:1:2:3/plain/my/image.jpg")
 				})
+
+				Convey("With RGBColor out of range returns an error", func() {
+					_, err := ip.Builder().
+						Background(RGBColor{R: -1}).
+						Generate("my/image.jpg")
+
+					So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+				})
 			})
 
 			Convey("Blur sets the blur option", func() {
@@ -271,41 +303,1411 @@ func Test_ImgproxyBuilder(t *testing.T) {
 				})
 			})
 
-			Convey("Preset sets the preset option", func() {
+			Convey("Rotate", func() {
+				Convey("With a multiple of 90 sets the option", func() {
+					url, err := ip.Builder().
+						Rotate(90).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/oFV2Nztm37FqZ36L1fLI/rot:90/plain/my/image.jpg")
+				})
+
+				Convey("With another angle returns an error", func() {
+					_, err := ip.Builder().
+						Rotate(45).
+						Generate("my/image.jpg")
+
+					So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+				})
+			})
+
+			Convey("AutoRotate sets the auto rotate option", func() {
 				url, err := ip.Builder().
-					Preset("foo", "bar").
+					AutoRotate(true).
 					Generate("my/image.jpg")
 
 				So(err, ShouldBeNil)
-				So(url, ShouldEqual, "http://localhost/16Ns_TrC2dRfO8dxVohb/pr:foo:bar/plain/my/image.jpg")
+				So(url, ShouldEqual, "http://localhost/0yhTxtktAjQAcK0EC45q/ar:1/plain/my/image.jpg")
 			})
 
-			Convey("CacheBuster sets the cacheBuster option", func() {
+			Convey("Flip sets the flip option", func() {
 				url, err := ip.Builder().
-					CacheBuster("foo").
+					Flip(true, false).
 					Generate("my/image.jpg")
 
 				So(err, ShouldBeNil)
-				So(url, ShouldEqual, "http://localhost/6FP7ES0ITuA5lFKCUjV4/cb:foo/plain/my/image.jpg")
+				So(url, ShouldEqual, "http://localhost/vuzH8p4qdZ89kkxYyw4Y/fl:1:0/plain/my/image.jpg")
 			})
 
-			Convey("Format sets the format option", func() {
+			Convey("BlurFloat sets the blur option", func() {
 				url, err := ip.Builder().
-					Format("png").
+					BlurFloat(0.5).
 					Generate("my/image.jpg")
 
 				So(err, ShouldBeNil)
-				So(url, ShouldEqual, "http://localhost/jXuXqfAktdBIyinMAcf8/f:png/plain/my/image.jpg")
+				So(url, ShouldEqual, "http://localhost/WS1mWQPzStPsBiHMnNZ8/bl:0.5/plain/my/image.jpg")
 			})
 
-			Convey("Crop sets the crop option", func() {
+			Convey("SharpenFloat", func() {
+				Convey("Sets the sharpen option", func() {
+					url, err := ip.Builder().
+						SharpenFloat(0.25).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/OkiuaDiTwqNi8uDvXLVE/sh:0.25/plain/my/image.jpg")
+				})
+
+				Convey("With a negative sigma returns an error", func() {
+					_, err := ip.Builder().
+						SharpenFloat(-1).
+						Generate("my/image.jpg")
+
+					So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+				})
+			})
+
+			Convey("UnsharpMasking", func() {
+				Convey("With all parameters sets the option", func() {
+					url, err := ip.Builder().
+						UnsharpMasking(UnsharpMaskingOptions{
+							Mode:    UnsharpMaskingModeAlways,
+							Weight:  2,
+							Divider: 24.5,
+						}).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/XpiFMiJHKg1fKfTaCrcQ/ush:always:2:24.5/plain/my/image.jpg")
+				})
+
+				Convey("With only the mode sets the option", func() {
+					url, err := ip.Builder().
+						UnsharpMasking(UnsharpMaskingOptions{
+							Mode: UnsharpMaskingModeNone,
+						}).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/qy3vJZJKMUD4-Vl_CMtW/ush:none/plain/my/image.jpg")
+				})
+			})
+
+			Convey("Pixelate sets the pixelate option", func() {
 				url, err := ip.Builder().
-					Crop(1, 2, GravityEnumCenter).
+					Pixelate(8).
 					Generate("my/image.jpg")
 
 				So(err, ShouldBeNil)
-				So(url, ShouldEqual, "http://localhost/00J_9T9UyVpOBQkQbodf/c:1:2:ce/plain/my/image.jpg")
+				So(url, ShouldEqual, "http://localhost/5TwYzZT5i1PKncFLgP2w/pix:8/plain/my/image.jpg")
 			})
-		})
-	})
+
+			Convey("Brightness", func() {
+				Convey("Within range sets the option", func() {
+					url, err := ip.Builder().
+						Brightness(-10).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/zYBbCv2rwJg2PGXMV4x5/br:-10/plain/my/image.jpg")
+				})
+
+				Convey("Out of range returns an error", func() {
+					_, err := ip.Builder().
+						Brightness(256).
+						Generate("my/image.jpg")
+
+					So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+				})
+			})
+
+			Convey("Contrast sets the contrast option", func() {
+				url, err := ip.Builder().
+					Contrast(1.5).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/lcWVp6gqrkFSmeOh95lN/co:1.5/plain/my/image.jpg")
+			})
+
+			Convey("Saturation sets the saturation option", func() {
+				url, err := ip.Builder().
+					Saturation(0.5).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/HA_HI_Ww8lEy1vQT6SOl/sa:0.5/plain/my/image.jpg")
+			})
+
+			Convey("Adjust", func() {
+				Convey("Sets the adjust option", func() {
+					url, err := ip.Builder().
+						Adjust(10, 1.2, 0.8).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/fHQaqFgCPXf_oj5tPTJi/a:10:1.2:0.8/plain/my/image.jpg")
+				})
+
+				Convey("With a negative contrast returns an error", func() {
+					_, err := ip.Builder().
+						Adjust(10, -1, 0.8).
+						Generate("my/image.jpg")
+
+					So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+				})
+			})
+
+			Convey("Monochrome", func() {
+				Convey("With HexColor sets the option", func() {
+					url, err := ip.Builder().
+						Monochrome(0.5, HexColor("#b3b3b3")).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/QDwETzUcfV7vNHpzYwjz/mc:0.5:b3b3b3/plain/my/image.jpg")
+				})
+
+				Convey("Without color sets the option", func() {
+					url, err := ip.Builder().
+						Monochrome(1, nil).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/O6HZnc8CD_yhfiWmYMNX/mc:1/plain/my/image.jpg")
+				})
+
+				Convey("Out of range returns an error", func() {
+					_, err := ip.Builder().
+						Monochrome(1.5, nil).
+						Generate("my/image.jpg")
+
+					So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+				})
+
+				Convey("With color channels out of range returns an error", func() {
+					_, err := ip.Builder().
+						Monochrome(0.5, RGBColor{R: 300, G: -1, B: 0}).
+						Generate("my/image.jpg")
+
+					So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+				})
+			})
+
+			Convey("Duotone sets the duotone option", func() {
+				url, err := ip.Builder().
+					Duotone(1, RGBColor{R: 0, G: 0, B: 255}, HexColor("ffff00")).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/s0CcgmmgSYRAA8YqVg3X/dt:1:0000ff:ffff00/plain/my/image.jpg")
+			})
+
+			Convey("Duotone with color channels out of range returns an error", func() {
+				_, err := ip.Builder().
+					Duotone(1, HexColor("000000"), RGBColor{R: 0, G: 0, B: 256}).
+					Generate("my/image.jpg")
+
+				So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+			})
+
+			Convey("WatermarkWithOptions", func() {
+				Convey("With all options sets the option", func() {
+					url, err := ip.Builder().
+						WatermarkWithOptions(WatermarkOptions{
+							Opacity:  0.5,
+							Position: WatermarkPositionSouthEast,
+							Offset:   &WatermarkOffset{X: 10, Y: 20},
+							Scale:    0.25,
+						}).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/HEXW8cueWEQN6Sm-35Ve/wm:0.5:soea:10:20:0.25/plain/my/image.jpg")
+				})
+
+				Convey("Omits the unset trailing arguments", func() {
+					url, err := ip.Builder().
+						WatermarkWithOptions(WatermarkOptions{
+							Opacity: 0.5,
+						}).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/yjhVXc4i3qzaS_xoyNkX/wm:0.5/plain/my/image.jpg")
+				})
+
+				Convey("With opacity out of range returns an error", func() {
+					_, err := ip.Builder().
+						WatermarkWithOptions(WatermarkOptions{
+							Opacity: 2,
+						}).
+						Generate("my/image.jpg")
+
+					So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+				})
+			})
+
+			Convey("WatermarkURL sets the base64 encoded watermark url option", func() {
+				url, err := ip.Builder().
+					WatermarkURL("https://example.com/logo.png").
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/OkgBzs0FXD4RjWefr7YJ/wmu:aHR0cHM6Ly9leGFtcGxlLmNvbS9sb2dvLnBuZw/plain/my/image.jpg")
+			})
+
+			Convey("WatermarkText sets the base64 encoded watermark text option", func() {
+				url, err := ip.Builder().
+					WatermarkText("<b>shop?</b>").
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/4uKnN3T9gv-uu3iFDDLY/wmt:PGI-c2hvcD88L2I-/plain/my/image.jpg")
+			})
+
+			Convey("WatermarkSize sets the watermark size option", func() {
+				url, err := ip.Builder().
+					WatermarkSize(100, 0).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/OZroLd81wET6RYrzGdLt/wms:100:0/plain/my/image.jpg")
+			})
+
+			Convey("WatermarkRotate sets the watermark rotate option", func() {
+				url, err := ip.Builder().
+					WatermarkRotate(-45).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/Yisr3VCv5cpp73tWMzbB/wmr:-45/plain/my/image.jpg")
+			})
+
+			Convey("WatermarkShadow sets the watermark shadow option", func() {
+				url, err := ip.Builder().
+					WatermarkShadow(1.5).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/516NnS6GFOZ9F84H99vw/wmsh:1.5/plain/my/image.jpg")
+			})
+
+			Convey("DisableAnimation sets the disable animation option", func() {
+				url, err := ip.Builder().
+					DisableAnimation(true).
+					Generate("my/image.gif")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/HRPEiN1A9xU5fe7b2D8c/da:1/plain/my/image.gif")
+			})
+
+			Convey("Page and Pages set the page options", func() {
+				url, err := ip.Builder().
+					Page(2).
+					Pages(3).
+					Generate("my/image.gif")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/qeCUjDhY5aZ0cSRNvyPW/pg:2/pgs:3/plain/my/image.gif")
+			})
+
+			Convey("VideoThumbnailSecond and VideoThumbnailKeyframes set the video thumbnail options", func() {
+				url, err := ip.Builder().
+					VideoThumbnailSecond(5).
+					VideoThumbnailKeyframes(true).
+					Generate("my/video.mp4")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/VafeiID7jyvFGjYJNuaO/vtk:1/vts:5/plain/my/video.mp4")
+			})
+
+			Convey("VideoThumbnailTile", func() {
+				tile := VideoThumbnailTile{
+					Step:       -1,
+					Columns:    4,
+					Rows:       3,
+					TileWidth:  160,
+					TileHeight: 90,
+					ExtendTile: true,
+				}
+
+				Convey("Sets the video thumbnail tile option", func() {
+					url, err := ip.Builder().
+						VideoThumbnailTile(tile).
+						Generate("my/video.mp4")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/0aU2ZjLrEVmFB4Hzkc2M/vtt:-1:4:3:160:90:1:0:0/plain/my/video.mp4")
+				})
+
+				Convey("Computes the sprite coordinates", func() {
+					width, height := tile.SpriteSize()
+					So(width, ShouldEqual, 640)
+					So(height, ShouldEqual, 270)
+					So(tile.TileCount(), ShouldEqual, 12)
+
+					x, y := tile.TilePosition(5)
+					So(x, ShouldEqual, 160)
+					So(y, ShouldEqual, 90)
+				})
+
+				Convey("With an empty grid returns an error", func() {
+					_, err := ip.Builder().
+						VideoThumbnailTile(VideoThumbnailTile{}).
+						Generate("my/video.mp4")
+
+					So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+				})
+			})
+
+			Convey("VideoThumbnailAnimation sets the video thumbnail animation option", func() {
+				url, err := ip.Builder().
+					VideoThumbnailAnimation(VideoThumbnailAnimation{
+						Step:        2.5,
+						Delay:       100,
+						Frames:      10,
+						FrameWidth:  320,
+						FrameHeight: 180,
+						Fill:        true,
+					}).
+					Generate("my/video.mp4")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/Ibllz5PjJyPMhjsSzMPN/vta:2.5:100:10:320:180:0:0:1/plain/my/video.mp4")
+			})
+
+			Convey("Metadata", func() {
+				Convey("Sets only the explicitly set options", func() {
+					url, err := ip.Builder().
+						Metadata(Metadata{
+							StripMetadata:     Bool(true),
+							KeepCopyright:     Bool(false),
+							StripColorProfile: Bool(true),
+							DPI:               300,
+						}).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/F3zJBCAmCY1lTJiL9n-j/dpi:300/kcr:0/scp:1/sm:1/plain/my/image.jpg")
+				})
+
+				Convey("Without fields sets nothing", func() {
+					url, err := ip.Builder().
+						Metadata(Metadata{}).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/s-cFqOcqN4HMtEZQwoyp/plain/my/image.jpg")
+				})
+			})
+
+			Convey("Limits", func() {
+				Convey("Sets only the non zero limits", func() {
+					url, err := ip.Builder().
+						Limits(Limits{
+							MaxSrcResolution:   16.5,
+							MaxSrcFileSize:     10485760,
+							MaxAnimationFrames: 10,
+							MaxResultDimension: 2000,
+						}).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/Z75xOftOg200SrFWBCUb/maf:10/mrd:2000/msfs:10485760/msr:16.5/plain/my/image.jpg")
+				})
+
+				Convey("With a negative limit returns an error", func() {
+					_, err := ip.Builder().
+						Limits(Limits{MaxAnimationFrameResolution: -1}).
+						Generate("my/image.jpg")
+
+					So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+				})
+			})
+
+			Convey("Preset sets the preset option", func() {
+				url, err := ip.Builder().
+					Preset("foo", "bar").
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/16Ns_TrC2dRfO8dxVohb/pr:foo:bar/plain/my/image.jpg")
+			})
+
+			Convey("CacheBuster sets the cacheBuster option", func() {
+				url, err := ip.Builder().
+					CacheBuster("foo").
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/6FP7ES0ITuA5lFKCUjV4/cb:foo/plain/my/image.jpg")
+			})
+
+			Convey("Filename", func() {
+				Convey("Sets the filename option", func() {
+					url, err := ip.Builder().
+						Filename("puppy.jpg", false).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/oT-oyYjJJ2CtHwbxrlwT/fn:puppy.jpg/plain/my/image.jpg")
+				})
+
+				Convey("With encode sets the encoded filename option", func() {
+					url, err := ip.Builder().
+						Filename("my:puppy.jpg", true).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/LbibjnElSKxYvl0y8ud_/fn:bXk6cHVwcHkuanBn:1/plain/my/image.jpg")
+				})
+
+				Convey("Without encode percent-encodes the filename", func() {
+					url, err := ip.Builder().
+						Filename("my puppy?#%.jpg", false).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/ar5UZ7bPE2RaoqqLv5if/fn:my%20puppy%3F%23%25.jpg/plain/my/image.jpg")
+				})
+
+				Convey("Without encode and reserved characters returns an error", func() {
+					_, err := ip.Builder().
+						Filename("my:puppy.jpg", false).
+						Generate("my/image.jpg")
+
+					So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+				})
+			})
+
+			Convey("ReturnAttachment sets the return attachment option", func() {
+				url, err := ip.Builder().
+					ReturnAttachment(true).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/SmmwW6lCaAaTtN80fFiZ/att:1/plain/my/image.jpg")
+			})
+
+			Convey("Expires sets the expires option", func() {
+				url, err := ip.Builder().
+					Expires(time.Unix(1700000000, 0)).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/uUq3Z-x10C4soqcTobtS/exp:1700000000/plain/my/image.jpg")
+			})
+
+			Convey("SkipProcessing sets the skip processing option", func() {
+				url, err := ip.Builder().
+					SkipProcessing(FormatEnumSVG, FormatEnumGIF).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/6Xg7cDV6vkJWX0UWTmpQ/skp:svg:gif/plain/my/image.jpg")
+			})
+
+			Convey("SkipProcessing without formats returns an error", func() {
+				_, err := ip.Builder().
+					SkipProcessing().
+					Generate("my/image.jpg")
+
+				So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+			})
+
+			Convey("Raw sets the raw option", func() {
+				url, err := ip.Builder().
+					Raw(true).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/SLteybcKZ7ht6JeX0ncY/raw:1/plain/my/image.jpg")
+			})
+
+			Convey("FallbackImageURL sets the base64 encoded fallback image url option", func() {
+				url, err := ip.Builder().
+					FallbackImageURL("https://example.com/placeholder.png").
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/t2Y4ttPM9E_Vo9h3MuKk/fiu:aHR0cHM6Ly9leGFtcGxlLmNvbS9wbGFjZWhvbGRlci5wbmc/plain/my/image.jpg")
+			})
+
+			Convey("MaxBytes sets the max bytes option", func() {
+				url, err := ip.Builder().
+					MaxBytes(102400).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/4sGWav-xqORWGZkeM8KO/mb:102400/plain/my/image.jpg")
+			})
+
+			Convey("Format sets the format option", func() {
+				url, err := ip.Builder().
+					Format("png").
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/jXuXqfAktdBIyinMAcf8/f:png/plain/my/image.jpg")
+			})
+
+			Convey("Then", func() {
+				Convey("Separates the pipelines", func() {
+					url, err := ip.Builder().
+						Crop(100, 100, GravityEnumCenter).
+						Then().
+						Resize(ResizingTypeFit, 50, 50, false, false).
+						WatermarkWithOptions(WatermarkOptions{Opacity: 1, Position: WatermarkPositionSouthEast}).
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/vAuWl36-axxEqnJfpYh9/c:100:100:ce/-/rs:fit:50:50:0:0/wm:1:soea/plain/my/image.jpg")
+				})
+
+				Convey("Skips the empty pipelines", func() {
+					url, err := ip.Builder().
+						Then().
+						Width(1).
+						Then().
+						Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/196LdHe9OIT7BZBGvnHF/w:1/plain/my/image.jpg")
+				})
+
+				Convey("Uses the Options field reassigned after Builder", func() {
+					data := ip.Builder()
+					data.Options = map[string]string{"w": "1"}
+
+					url, err := data.Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/196LdHe9OIT7BZBGvnHF/w:1/plain/my/image.jpg")
+
+					data = ip.Builder().Width(1).Then()
+					data.Options = map[string]string{"h": "1"}
+
+					url, err = data.Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/kBrzmC-PJu-mr5xgIOVs/w:1/-/h:1/plain/my/image.jpg")
+				})
+
+				Convey("Keeps the options of data built without Builder", func() {
+					data := &ImgproxyURLData{
+						Imgproxy: ip,
+						Options:  map[string]string{"w": "1"},
+					}
+
+					url, err := data.Then().Height(1).Generate("my/image.jpg")
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "http://localhost/kBrzmC-PJu-mr5xgIOVs/w:1/-/h:1/plain/my/image.jpg")
+				})
+			})
+
+			Convey("Crop sets the crop option", func() {
+				url, err := ip.Builder().
+					Crop(1, 2, GravityEnumCenter).
+					Generate("my/image.jpg")
+
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/00J_9T9UyVpOBQkQbodf/c:1:2:ce/plain/my/image.jpg")
+			})
+		})
+	})
+}
+
+func Test_ImgproxyOptionOrder(t *testing.T) {
+	Convey("Config.OptionOrder", t, func() {
+		cfg := Config{
+			BaseURL:       "http://localhost",
+			SignatureSize: 15,
+			Key:           hex.EncodeToString([]byte("key")),
+			Salt:          hex.EncodeToString([]byte("salt")),
+			EncodePath:    false,
+		}
+
+		generate := func(order OptionOrder) string {
+			cfg.OptionOrder = order
+
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			url, err := ip.Builder().
+				Quality(80).
+				Width(100).
+				Preset("thumb").
+				Blur(2).
+				Width(200).
+				Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+
+			return url
+		}
+
+		Convey("Sorted sorts the options alphabetically", func() {
+			So(generate(OptionOrderSorted), ShouldEqual, "http://localhost/9AgORMDzyfhg67tzKm_G/bl:2/pr:thumb/q:80/w:200/plain/my/image.jpg")
+		})
+
+		Convey("Insertion keeps the order in which options were first set", func() {
+			So(generate(OptionOrderInsertion), ShouldEqual, "http://localhost/iVH_yH2iyH8Y5D9GJHAp/q:80/w:200/pr:thumb/bl:2/plain/my/image.jpg")
+		})
+
+		Convey("Canonical follows the imgproxy processing order", func() {
+			So(generate(OptionOrderCanonical), ShouldEqual, "http://localhost/dLRozRFcbL-VD28cMVzj/pr:thumb/w:200/bl:2/q:80/plain/my/image.jpg")
+		})
+	})
+}
+
+func Test_ImgproxyNormalize(t *testing.T) {
+	Convey("ImgproxyURLData.Normalize()", t, func() {
+		ip, err := NewImgproxy(Config{
+			BaseURL:       "http://localhost",
+			SignatureSize: 15,
+			Key:           hex.EncodeToString([]byte("key")),
+			Salt:          hex.EncodeToString([]byte("salt")),
+			EncodePath:    false,
+		})
+		So(err, ShouldBeNil)
+
+		Convey("Collapses the equivalent resize options", func() {
+			expected := "http://localhost/YeHtmhLzlLfoFjBSf4YL/rs:fill:300/plain/my/image.jpg"
+
+			for _, data := range []*ImgproxyURLData{
+				ip.Builder().Resize(ResizingTypeFill, 300, 0, false, false),
+				ip.Builder().SetOption("rs", "fill:300"),
+				ip.Builder().ResizingType(ResizingTypeFill).Width(300),
+				ip.Builder().SetOption("resizing_type", "fill").SetOption("width", "300").SetOption("enlarge", "false"),
+			} {
+				url, err := data.Normalize().Generate("my/image.jpg")
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, expected)
+			}
+		})
+
+		Convey("Applies the resize options in URL order", func() {
+			url, err := ip.Builder().
+				Width(100).
+				Resize(ResizingTypeFit, 0, 200, true, false).
+				Normalize().
+				Generate("my/image.jpg")
+
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/qe-kSRKAQZtUsXxW_CwX/rs:fit:100:200:1/plain/my/image.jpg")
+
+			url, err = ip.Builder().
+				Width(300).
+				Resize(ResizingTypeFill, 100, 0, false, false).
+				Normalize().
+				Generate("my/image.jpg")
+
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/YeHtmhLzlLfoFjBSf4YL/rs:fill:300/plain/my/image.jpg")
+		})
+
+		Convey("Applies the resize options in insertion order", func() {
+			ip, err := NewImgproxy(Config{
+				BaseURL:       "http://localhost",
+				SignatureSize: 15,
+				Key:           hex.EncodeToString([]byte("key")),
+				Salt:          hex.EncodeToString([]byte("salt")),
+				EncodePath:    false,
+				OptionOrder:   OptionOrderInsertion,
+			})
+			So(err, ShouldBeNil)
+
+			url, err := ip.Builder().
+				Width(100).
+				Resize(ResizingTypeFit, 0, 200, true, false).
+				Normalize().
+				Generate("my/image.jpg")
+
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/yHnyggmUiME-9oktx54Y/rs:fit:0:200:1/plain/my/image.jpg")
+		})
+
+		Convey("Keeps the default options overriding a preset", func() {
+			url, err := ip.Builder().
+				Preset("thumb").
+				Width(0).
+				DPR(1).
+				Normalize().
+				Generate("my/image.jpg")
+
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/6cKsut7qAVc7C2TWnkt3/dpr:1/pr:thumb/w:0/plain/my/image.jpg")
+
+			url, err = ip.Builder().
+				SetOption("preset", "p").
+				SetOption("enlarge", "false").
+				Normalize().
+				Generate("my/image.jpg")
+
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/Lg67WCsBtk180CcoPKcy/el:0/pr:p/plain/my/image.jpg")
+		})
+
+		Convey("Drops the default options", func() {
+			url, err := ip.Builder().
+				Resize(ResizingTypeFit, 0, 0, false, false).
+				DPR(1).
+				Blur(0).
+				Quality(80).
+				SetOption("auto_rotate", "true").
+				Normalize().
+				Generate("my/image.jpg")
+
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/lVzfJrxIaL4p19lws4Xb/ar:1/q:80/plain/my/image.jpg")
+		})
+
+		Convey("Keeps the resize options it can't interpret", func() {
+			url, err := ip.Builder().
+				SetOption("rs", "fill:300:200:0:1:ce:0:0").
+				Normalize().
+				Generate("my/image.jpg")
+
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/vww5NJ5aY8IIzalB4lYJ/rs:fill:300:200:0:1:ce:0:0/plain/my/image.jpg")
+		})
+
+		Convey("Normalizes each pipeline of a parsed URL", func() {
+			path := "/rt:fill/w:300/h:0/-/resize:fit:100/plain/my/image.jpg"
+			signature, err := ip.sign(context.Background(), path)
+			So(err, ShouldBeNil)
+
+			data, source, err := ip.ParsePath("/" + signature + path)
+			So(err, ShouldBeNil)
+
+			url, err := data.Normalize().Generate(source)
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/n5p5Qpjc7kjN7BaqE9Hl/rs:fill:300/-/rs:fit:100/plain/my/image.jpg")
+		})
+	})
+}
+
+type fakeSigner struct {
+	payloads []string
+	retained [][]byte
+	err      error
+}
+
+func (f *fakeSigner) Sign(_ context.Context, payload []byte) ([]byte, error) {
+	f.payloads = append(f.payloads, string(payload))
+	f.retained = append(f.retained, payload)
+	return []byte("0123456789abcdef"), f.err
+}
+
+func Test_ImgproxySigner(t *testing.T) {
+	Convey("Config.Signer", t, func() {
+		signer := &fakeSigner{}
+		cfg := Config{
+			BaseURL:       "http://localhost",
+			SignatureSize: 8,
+			Signer:        signer,
+		}
+
+		Convey("Signs the path with the configured signer", func() {
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			url, err := ip.Builder().Width(10).Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/MDEyMzQ1Njc/w:10/plain/my/image.jpg")
+			So(signer.payloads, ShouldResemble, []string{"/w:10/plain/my/image.jpg"})
+		})
+
+		Convey("Lets the signer retain the payload", func() {
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			_, err = ip.Builder().Width(10).Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+			_, err = ip.Builder().Height(20).Generate("my/other.jpg")
+			So(err, ShouldBeNil)
+
+			So(string(signer.retained[0]), ShouldEqual, "/w:10/plain/my/image.jpg")
+			So(string(signer.retained[1]), ShouldEqual, "/h:20/plain/my/other.jpg")
+		})
+
+		Convey("Returns the signer error", func() {
+			signer.err = errors.New("unavailable")
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			_, err = ip.Builder().Generate("my/image.jpg")
+			So(errors.Cause(err), ShouldEqual, signer.err)
+		})
+
+		Convey("Returns an error when the signature is shorter than the signature size", func() {
+			cfg.SignatureSize = 32
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			_, err = ip.Builder().Generate("my/image.jpg")
+			So(errors.Cause(err), ShouldResemble, ErrInvalidSignature)
+		})
+
+		Convey("HMACSigner signs like the configured key and salt", func() {
+			key, salt := []byte("key"), []byte("salt")
+			ip, err := NewImgproxy(Config{
+				BaseURL:       "http://localhost",
+				SignatureSize: 15,
+				Signer:        HMACSigner{Key: key, Salt: salt},
+			})
+			So(err, ShouldBeNil)
+
+			url, err := ip.Builder().Width(1).Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/196LdHe9OIT7BZBGvnHF/w:1/plain/my/image.jpg")
+		})
+	})
+}
+
+func Test_ImgproxyBatch(t *testing.T) {
+	Convey("Batch generation", t, func() {
+		ip, err := NewImgproxy(Config{
+			BaseURL:       "http://localhost",
+			SignatureSize: 15,
+			Key:           hex.EncodeToString([]byte("key")),
+			Salt:          hex.EncodeToString([]byte("salt")),
+			EncodePath:    false,
+		})
+		So(err, ShouldBeNil)
+
+		sources := []string{"my/image.jpg", "my/other.jpg", "my/third.jpg"}
+
+		Convey("GenerateBatch generates the same URLs as Generate", func() {
+			builder := ip.Builder().Width(100).Quality(80)
+
+			urls, err := builder.GenerateBatch(sources)
+			So(err, ShouldBeNil)
+			So(urls, ShouldHaveLength, len(sources))
+
+			for j, source := range sources {
+				url, err := builder.Generate(source)
+				So(err, ShouldBeNil)
+				So(urls[j], ShouldEqual, url)
+			}
+		})
+
+		Convey("GenerateBatch returns the builder error", func() {
+			_, err := ip.Builder().Rotate(45).GenerateBatch(sources)
+			So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+		})
+
+		Convey("GenerateItems", func() {
+			small := ip.Builder().Width(100)
+			large := ip.Builder().Width(1000)
+			invalid := ip.Builder().Rotate(45)
+
+			var items []BatchItem
+			for _, source := range sources {
+				items = append(items,
+					BatchItem{Builder: small, Source: source},
+					BatchItem{Builder: large, Source: source},
+					BatchItem{Builder: invalid, Source: source},
+				)
+			}
+
+			for _, workers := range []int{0, 4} {
+				Convey(fmt.Sprintf("With %d workers returns the results in order", workers), func() {
+					results := GenerateItems(context.Background(), items, workers)
+					So(results, ShouldHaveLength, len(items))
+
+					for j, item := range items {
+						url, err := item.Builder.Generate(item.Source)
+						So(results[j].URL, ShouldEqual, url)
+						So(errors.Cause(results[j].Err), ShouldEqual, errors.Cause(err))
+					}
+				})
+			}
+
+			Convey("Without builder returns an error for the item", func() {
+				results := GenerateItems(context.Background(), []BatchItem{
+					{Source: "my/image.jpg"},
+					items[0],
+				}, 4)
+
+				So(errors.Cause(results[0].Err), ShouldEqual, ErrInvalidOption)
+				So(results[1].Err, ShouldBeNil)
+				So(results[1].URL, ShouldNotBeEmpty)
+			})
+
+			Convey("With a done context returns its error", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				results := GenerateItems(ctx, items[:1], 0)
+				So(results[0].Err, ShouldEqual, context.Canceled)
+			})
+		})
+	})
+}
+
+func Test_ImgproxyPlainSourceEscaping(t *testing.T) {
+	Convey("Plain source escaping", t, func() {
+		cfg := Config{
+			BaseURL:       "http://localhost",
+			SignatureSize: 15,
+			Key:           hex.EncodeToString([]byte("key")),
+			Salt:          hex.EncodeToString([]byte("salt")),
+			EncodePath:    false,
+		}
+		source := "http://example.com/my image@2x.jpg?size=100%#top"
+
+		Convey("Escapes the characters imgproxy would misread", func() {
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			url, err := ip.Builder().Generate(source)
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/YVw_rKZqpKk6fybuVKnE/plain/http://example.com/my%20image%402x.jpg%3Fsize=100%25%23top")
+
+			data, parsed, err := ip.Parse(url)
+			So(err, ShouldBeNil)
+			So(parsed, ShouldEqual, source)
+			So(data.Pipelines(), ShouldResemble, [][]Option{{}})
+		})
+
+		Convey("With UnescapedPlainSources keeps the source as it is", func() {
+			cfg.UnescapedPlainSources = true
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			url, err := ip.Builder().Generate("my/image%20name.jpg")
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/O8YXANKR0DmSRF6noXRP/plain/my/image%20name.jpg")
+
+			_, parsed, err := ip.Parse(url)
+			So(err, ShouldBeNil)
+			So(parsed, ShouldEqual, "my/image%20name.jpg")
+		})
+	})
+}
+
+func Test_ImgproxySourceFilename(t *testing.T) {
+	Convey("ImgproxyURLData.SourceFilename()", t, func() {
+		cfg := Config{
+			BaseURL:                   "http://localhost",
+			SignatureSize:             15,
+			Key:                       hex.EncodeToString([]byte("key")),
+			Salt:                      hex.EncodeToString([]byte("salt")),
+			EncodePath:                true,
+			Base64URLIncludesFilename: true,
+		}
+
+		Convey("Appends the sanitized filename to the encoded source", func() {
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			url, err := ip.Builder().
+				Width(100).
+				SourceFilename("cute puppy/é.jpg").
+				Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/3DMNzocIRjGaU9ul-t9l/w:100/bXkvaW1hZ2UuanBn/cute-puppy---.jpg")
+
+			data, source, err := ip.Parse(url)
+			So(err, ShouldBeNil)
+			So(source, ShouldEqual, "my/image.jpg")
+
+			regenerated, err := data.Generate(source)
+			So(err, ShouldBeNil)
+			So(regenerated, ShouldEqual, url)
+		})
+
+		Convey("Is ignored when the imgproxy URLs don't include filenames", func() {
+			cfg.Base64URLIncludesFilename = false
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			url, err := ip.Builder().
+				SourceFilename("puppy.jpg").
+				Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/6wIzqvuZtfHT1LL3J_z0/bXkvaW1hZ2UuanBn")
+		})
+
+		Convey("Encodes the source with URL-safe base64", func() {
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			url, err := ip.Builder().Generate("my/image.jpg?>>??")
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/wroDSSOA4E8NhXq5lNxT/bXkvaW1hZ2UuanBnPz4-Pz8")
+		})
+	})
+}
+
+func Test_ImgproxySource(t *testing.T) {
+	Convey("Sources", t, func() {
+		Convey("Build the source URLs", func() {
+			So(HTTPSource("https://example.com/a.jpg").SourceURL(), ShouldEqual, "https://example.com/a.jpg")
+			So(S3Source("bucket", "path/my image?.jpg", "v1").SourceURL(), ShouldEqual, "s3://bucket/path/my%20image%3F.jpg?v1")
+			So(S3Source("bucket", "image.jpg", "").SourceURL(), ShouldEqual, "s3://bucket/image.jpg")
+			So(GCSSource("bucket", "image.jpg", "1360887759327000").SourceURL(), ShouldEqual, "gs://bucket/image.jpg?1360887759327000")
+			So(ABSSource("container", "dir/image.jpg").SourceURL(), ShouldEqual, "abs://container/dir/image.jpg")
+			So(SwiftSource("container", "image.jpg").SourceURL(), ShouldEqual, "swift://container/image.jpg")
+			So(LocalSource("/images/image@2x.jpg").SourceURL(), ShouldEqual, "local:///images/image@2x.jpg")
+		})
+
+		Convey("GenerateSource", func() {
+			cfg := Config{
+				BaseURL:       "http://localhost",
+				SignatureSize: 15,
+				Key:           hex.EncodeToString([]byte("key")),
+				Salt:          hex.EncodeToString([]byte("salt")),
+				EncodePath:    false,
+			}
+
+			Convey("Escapes plain sources", func() {
+				ip, err := NewImgproxy(cfg)
+				So(err, ShouldBeNil)
+
+				url, err := ip.Builder().GenerateSource(S3Source("bucket", "my image@2x.jpg", "v1"))
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/lEy4lJ2TFRluvTuHK5Mt/plain/s3://bucket/my%2520image%402x.jpg%3Fv1")
+			})
+
+			Convey("Encodes the source URL", func() {
+				cfg.EncodePath = true
+				ip, err := NewImgproxy(cfg)
+				So(err, ShouldBeNil)
+
+				url, err := ip.Builder().GenerateSource(LocalSource("image.jpg"))
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "http://localhost/6p-L9zNxYOBtmOcxWG6L/bG9jYWw6Ly8vaW1hZ2UuanBn")
+			})
+		})
+	})
+}
+
+func Test_ImgproxyBaseURLs(t *testing.T) {
+	Convey("Config.BaseURLs", t, func() {
+		cfg := Config{
+			BaseURLs:      []string{"http://img1.localhost", "http://img2.localhost/", "http://img3.localhost"},
+			SignatureSize: 15,
+			Key:           hex.EncodeToString([]byte("key")),
+			Salt:          hex.EncodeToString([]byte("salt")),
+			EncodePath:    false,
+		}
+
+		Convey("Maps the same source to the same base URL", func() {
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			hosts := map[string]bool{}
+			for j := 0; j < 20; j++ {
+				source := fmt.Sprintf("my/image%d.jpg", j)
+
+				url, err := ip.Builder().Width(1).Generate(source)
+				So(err, ShouldBeNil)
+
+				again, err := ip.Builder().Width(1).Generate(source)
+				So(err, ShouldBeNil)
+				So(again, ShouldEqual, url)
+
+				hosts[url[:len("http://img1.localhost")]] = true
+			}
+
+			So(hosts, ShouldHaveLength, 3)
+		})
+
+		Convey("Keeps the path and signature of a single base URL", func() {
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			url, err := ip.Builder().Width(1).Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+			So(url, ShouldEndWith, ".localhost/196LdHe9OIT7BZBGvnHF/w:1/plain/my/image.jpg")
+
+			_, source, err := ip.Parse(url)
+			So(err, ShouldBeNil)
+			So(source, ShouldEqual, "my/image.jpg")
+		})
+
+		Convey("RoundRobinSelector cycles through the base URLs", func() {
+			cfg.BaseURLSelector = &RoundRobinSelector{}
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			for _, host := range []string{"img1", "img2", "img3", "img1"} {
+				url, err := ip.Builder().Generate("my/image.jpg")
+				So(err, ShouldBeNil)
+				So(url, ShouldStartWith, "http://"+host+".localhost/")
+			}
+		})
+
+		Convey("WeightedSelector never selects a base URL without weight", func() {
+			cfg.BaseURLSelector = WeightedSelector{Weights: []uint32{1, 0, 3}}
+			ip, err := NewImgproxy(cfg)
+			So(err, ShouldBeNil)
+
+			for j := 0; j < 20; j++ {
+				url, err := ip.Builder().Generate(fmt.Sprintf("my/image%d.jpg", j))
+				So(err, ShouldBeNil)
+				So(url, ShouldNotStartWith, "http://img2.localhost/")
+			}
+		})
+	})
+}
+
+func Test_ImgproxyGeneratePath(t *testing.T) {
+	Convey("Path generation", t, func() {
+		ip, err := NewImgproxy(Config{
+			BaseURL:       "http://localhost",
+			SignatureSize: 15,
+			Key:           hex.EncodeToString([]byte("key")),
+			Salt:          hex.EncodeToString([]byte("salt")),
+			EncodePath:    false,
+		})
+		So(err, ShouldBeNil)
+
+		builder := ip.Builder().Width(1)
+
+		Convey("GeneratePath returns the signed path without base URL", func() {
+			path, err := builder.GeneratePath("my/image.jpg")
+			So(err, ShouldBeNil)
+			So(path, ShouldEqual, "/196LdHe9OIT7BZBGvnHF/w:1/plain/my/image.jpg")
+
+			url, err := builder.Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost"+path)
+		})
+
+		Convey("GenerateSignature returns the signature and the signed path", func() {
+			signature, path, err := builder.GenerateSignature("my/image.jpg")
+			So(err, ShouldBeNil)
+			So(signature, ShouldEqual, "196LdHe9OIT7BZBGvnHF")
+			So(path, ShouldEqual, "/w:1/plain/my/image.jpg")
+		})
+
+		Convey("GeneratePath returns the builder error", func() {
+			_, err := ip.Builder().Rotate(1).GeneratePath("my/image.jpg")
+			So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+		})
+	})
+}
+
+func Test_ImgproxyInfoBuilder(t *testing.T) {
+	Convey("Imgproxy.InfoBuilder()", t, func() {
+		ip, err := NewImgproxy(Config{
+			BaseURL:       "http://localhost",
+			SignatureSize: 15,
+			Key:           hex.EncodeToString([]byte("key")),
+			Salt:          hex.EncodeToString([]byte("salt")),
+			EncodePath:    false,
+		})
+		So(err, ShouldBeNil)
+
+		Convey("Generates the info URL with the same signature as the path", func() {
+			builder := ip.InfoBuilder().
+				Size(true).
+				Format(true).
+				Dimensions(true).
+				EXIF(true).
+				IPTC(false).
+				VideoMeta(true).
+				DetectObjects(true).
+				Colorspace(true).
+				Bands(true).
+				Palette(8).
+				Average(true, false).
+				DominantColors(true, true).
+				Blurhash(4, 3)
+
+			url, err := builder.Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "http://localhost/info/1ax-1skKpKBXqZenalZ3/avg:1:0/b:1/bh:4:3/cs:1/d:1/dc:1:1/do:1/exif:1/f:1/iptc:0/p:8/s:1/vm:1/plain/my/image.jpg")
+
+			path, err := builder.GeneratePath("my/image.jpg")
+			So(err, ShouldBeNil)
+			So("http://localhost"+path, ShouldEqual, url)
+		})
+
+		Convey("Returns an error when an option is out of range", func() {
+			_, err := ip.InfoBuilder().Blurhash(10, 1).Generate("my/image.jpg")
+			So(errors.Cause(err), ShouldResemble, ErrInvalidOption)
+		})
+	})
+
+	Convey("DecodeInfo()", t, func() {
+		info, err := DecodeInfo(strings.NewReader(`{
+			"size": 1024,
+			"format": "jpeg",
+			"width": 300,
+			"height": 200,
+			"exif": {"Make": "Canon"},
+			"objects": [{"class_id": 1, "class_name": "dog", "confidence": 0.9, "left": 0.1, "top": 0.2, "width": 0.5, "height": 0.6}],
+			"average": {"R": 10, "G": 20, "B": 30, "A": 255},
+			"dominant_colors": {"vibrant": {"R": 255, "G": 0, "B": 0, "A": 255}},
+			"blurhash": "LEHV6nWB2yk8pyo0adR*.7kCMdnj"
+		}`))
+		So(err, ShouldBeNil)
+		So(info.Size, ShouldEqual, 1024)
+		So(info.Format, ShouldEqual, "jpeg")
+		So(info.Width, ShouldEqual, 300)
+		So(info.Height, ShouldEqual, 200)
+		So(info.EXIF["Make"], ShouldEqual, "Canon")
+		So(info.Objects, ShouldResemble, []InfoObject{{ClassID: 1, ClassName: "dog", Confidence: 0.9, Left: 0.1, Top: 0.2, Width: 0.5, Height: 0.6}})
+		So(*info.Average, ShouldResemble, InfoColor{R: 10, G: 20, B: 30, A: 255})
+		So(info.DominantColors["vibrant"].GetHexOption(), ShouldEqual, "ff0000")
+		So(info.Blurhash, ShouldEqual, "LEHV6nWB2yk8pyo0adR*.7kCMdnj")
+	})
+}
+
+func Test_ImgproxyParse(t *testing.T) {
+	Convey("Imgproxy.Parse()", t, func() {
+		ip, err := NewImgproxy(Config{
+			BaseURL:       "http://localhost",
+			SignatureSize: 15,
+			Key:           hex.EncodeToString([]byte("key")),
+			Salt:          hex.EncodeToString([]byte("salt")),
+			EncodePath:    false,
+		})
+		So(err, ShouldBeNil)
+
+		Convey("Parses the pipelines and the plain source", func() {
+			path := "/c:100:100:ce/-/rs:fit:50:50:0:0/wm:1:soea/plain/my/image.jpg@png"
+			signature, err := ip.sign(context.Background(), path)
+			So(err, ShouldBeNil)
+
+			data, source, err := ip.Parse("http://localhost/" + signature + path)
+
+			So(err, ShouldBeNil)
+			So(source, ShouldEqual, "my/image.jpg")
+			So(data.Pipelines(), ShouldResemble, [][]Option{
+				{{Key: "c", Value: "100:100:ce"}},
+				{{Key: "rs", Value: "fit:50:50:0:0"}, {Key: "wm", Value: "1:soea"}, {Key: "f", Value: "png"}},
+			})
+		})
+
+		Convey("Parses the encoded source", func() {
+			data, source, err := ip.ParsePath("/6wIzqvuZtfHT1LL3J_z0/bXkvaW1hZ2UuanBn")
+
+			So(err, ShouldBeNil)
+			So(source, ShouldEqual, "my/image.jpg")
+			So(data.Pipelines(), ShouldResemble, [][]Option{{}})
+		})
+
+		Convey("Round trips a generated URL", func() {
+			url, err := ip.Builder().
+				Width(10).
+				Then().
+				Blur(2).
+				Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+
+			data, source, err := ip.Parse(url)
+			So(err, ShouldBeNil)
+
+			regenerated, err := data.Generate(source)
+			So(err, ShouldBeNil)
+			So(regenerated, ShouldEqual, url)
+		})
+
+		Convey("Ignores the query and fragment", func() {
+			url, err := ip.Builder().Width(10).Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+
+			data, source, err := ip.Parse(url + "?foo=bar#top")
+			So(err, ShouldBeNil)
+			So(source, ShouldEqual, "my/image.jpg")
+			So(data.Pipelines(), ShouldResemble, [][]Option{{{Key: "w", Value: "10"}}})
+		})
+
+		Convey("Returns an error when the signature does not match", func() {
+			_, _, err := ip.Parse("http://localhost/6wIzqvuZtfHT1LL3J_z0/w:10/plain/my/image.jpg")
+
+			So(errors.Cause(err), ShouldResemble, ErrSignatureMismatch)
+		})
+
+		Convey("Returns an error when the source is missing", func() {
+			_, _, err := ip.ParsePath("/signature")
+
+			So(errors.Cause(err), ShouldResemble, ErrInvalidURL)
+		})
+	})
+}
+
+func benchmarkImgproxy(b *testing.B, encodePath bool) *Imgproxy {
+	ip, err := NewImgproxy(Config{
+		BaseURL:       "http://localhost",
+		SignatureSize: 32,
+		Key:           hex.EncodeToString([]byte("key")),
+		Salt:          hex.EncodeToString([]byte("salt")),
+		EncodePath:    encodePath,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return ip
+}
+
+func Benchmark_Generate(b *testing.B) {
+	builder := benchmarkImgproxy(b, false).Builder().
+		Resize(ResizingTypeFill, 300, 400, true, false).
+		Gravity(GravityEnumSmart).
+		Quality(80).
+		Format("webp")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := builder.Generate("https://example.com/images/product/123456789.jpg"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_GenerateBatch(b *testing.B) {
+	builder := benchmarkImgproxy(b, false).Builder().
+		Resize(ResizingTypeFill, 300, 400, true, false).
+		Gravity(GravityEnumSmart).
+		Quality(80).
+		Format("webp")
+
+	sources := make([]string, 100)
+	for j := range sources {
+		sources[j] = fmt.Sprintf("https://example.com/images/product/%d.jpg", j)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := builder.GenerateBatch(sources); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_AppendURL(b *testing.B) {
+	for _, encodePath := range []bool{false, true} {
+		b.Run(fmt.Sprintf("EncodePath=%t", encodePath), func(b *testing.B) {
+			builder := benchmarkImgproxy(b, encodePath).Builder().
+				Resize(ResizingTypeFill, 300, 400, true, false).
+				Gravity(GravityEnumSmart).
+				Quality(80).
+				Format("webp")
+
+			dst := make([]byte, 0, 512)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for n := 0; n < b.N; n++ {
+				var err error
+				if dst, err = builder.AppendURL(dst[:0], "https://example.com/images/product/123456789.jpg"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
 }
@@ -0,0 +1,248 @@
+// Package imgproxytest provides an in-process fake imgproxy server for tests.
+package imgproxytest
+
+import (
+	"encoding/json"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/unitedwardrobe/imgproxy-go"
+)
+
+// DefaultSize is the width and height of the placeholder image when the request doesn't set them.
+const DefaultSize = 100
+
+// MaxSize is the maximum width and height of the placeholder image.
+const MaxSize = 4096
+
+// Request holds a request received by the server.
+type Request struct {
+	Path      string
+	Info      bool
+	Source    string
+	Pipelines [][]imgproxy.Option
+	// Width, Height and Format of the placeholder image returned for the request.
+	Width  int
+	Height int
+	Format string
+}
+
+// Server is a fake imgproxy server. It verifies the signatures with the same configuration as the
+// URL builder, parses the options and returns a placeholder image sized per the options.
+type Server struct {
+	*httptest.Server
+	ip   *imgproxy.Imgproxy
+	echo bool
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// NewServer starts a fake imgproxy server. The base URLs of the configuration are replaced by the
+// server URL, use Imgproxy to generate URLs for it. The server must be closed by the caller.
+//
+// The server also responds to /health, without version in the Server header.
+//
+// The placeholder image is a gray PNG, JPEG or GIF, depending on the format option, and defaults to PNG.
+// Its size is set by the rs, s, w, h and dpr options of the last pipeline, ignoring the resizing type.
+// When only one dimension is set, the image is square, and when none is, it is DefaultSize wide and high.
+// Requests for negative dimensions or for an image larger than MaxSize get 404 Not Found,
+// as imgproxy responds to invalid options.
+func NewServer(cfg imgproxy.Config) (*Server, error) {
+	return newServer(cfg, false)
+}
+
+// NewEchoServer starts a fake imgproxy server like NewServer, which responds with the JSON of the Request
+// instead of the placeholder image.
+func NewEchoServer(cfg imgproxy.Config) (*Server, error) {
+	return newServer(cfg, true)
+}
+
+func newServer(cfg imgproxy.Config, echo bool) (*Server, error) {
+	s := &Server{echo: echo}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	cfg.BaseURL = s.URL
+	cfg.BaseURLs = nil
+
+	ip, err := imgproxy.NewImgproxy(cfg)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.ip = ip
+
+	return s, nil
+}
+
+// Imgproxy returns the URL builder helper for the server.
+func (s *Server) Imgproxy() *imgproxy.Imgproxy {
+	return s.ip
+}
+
+// Requests returns the valid requests received by the server, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	if path == "/health" {
+		w.Header().Set("Server", "imgproxy")
+		io.WriteString(w, "imgproxy is running")
+		return
+	}
+
+	signedPath := path
+	info := strings.HasPrefix(path, "/info/")
+	if info {
+		signedPath = path[len("/info"):]
+	}
+
+	data, source, err := s.ip.ParsePath(signedPath)
+	switch {
+	case errors.Is(err, imgproxy.ErrSignatureMismatch):
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	case err != nil:
+		http.Error(w, "Invalid URL", http.StatusNotFound)
+		return
+	}
+
+	req := Request{
+		Path:      path,
+		Info:      info,
+		Source:    source,
+		Pipelines: data.Pipelines(),
+	}
+	req.Width, req.Height, req.Format, err = placeholder(req.Pipelines)
+	if err != nil {
+		http.Error(w, "Invalid options: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	switch {
+	case s.echo:
+		writeJSON(w, req)
+	case info:
+		writeJSON(w, imgproxy.Info{Width: req.Width, Height: req.Height, Format: req.Format})
+	default:
+		writeImage(w, req)
+	}
+}
+
+// placeholder returns the size and format of the placeholder image for the pipelines.
+func placeholder(pipelines [][]imgproxy.Option) (int, int, string, error) {
+	width, height, dpr, format := 0, 0, 1, "png"
+
+	if len(pipelines) > 0 {
+		for _, option := range pipelines[len(pipelines)-1] {
+			args := strings.Split(option.Value, ":")
+
+			switch option.Key {
+			case "rs", "resize":
+				width, height = dimensionArgs(args[1:], width, height)
+			case "s", "size":
+				width, height = dimensionArgs(args, width, height)
+			case "w", "width":
+				width, _ = dimensionArgs(args, width, height)
+			case "h", "height":
+				height, _ = dimensionArgs(args, height, width)
+			case "dpr":
+				if n, err := strconv.Atoi(args[0]); err == nil && n > 0 {
+					dpr = n
+				}
+			case "f", "format", "ext":
+				format = option.Value
+			}
+		}
+	}
+
+	if width < 0 || height < 0 {
+		return 0, 0, "", errors.Errorf("negative dimensions %dx%d", width, height)
+	}
+
+	switch {
+	case width == 0 && height == 0:
+		width, height = DefaultSize, DefaultSize
+	case width == 0:
+		width = height
+	case height == 0:
+		height = width
+	}
+
+	if width > MaxSize/dpr || height > MaxSize/dpr {
+		return 0, 0, "", errors.Errorf("dimensions %dx%d at dpr %d exceed %d", width, height, dpr, MaxSize)
+	}
+
+	return width * dpr, height * dpr, format, nil
+}
+
+// dimensionArgs returns the dimensions from the first two arguments, keeping the current ones when missing.
+func dimensionArgs(args []string, width int, height int) (int, int) {
+	if len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			width = n
+		}
+	}
+
+	if len(args) > 1 {
+		if n, err := strconv.Atoi(args[1]); err == nil {
+			height = n
+		}
+	}
+
+	return width, height
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeImage(w http.ResponseWriter, req Request) {
+	img := image.NewGray(image.Rect(0, 0, req.Width, req.Height))
+	for j := range img.Pix {
+		img.Pix[j] = 0x80
+	}
+
+	w.Header().Set("X-Result-Width", strconv.Itoa(req.Width))
+	w.Header().Set("X-Result-Height", strconv.Itoa(req.Height))
+
+	var err error
+
+	switch req.Format {
+	case "jpg", "jpeg":
+		w.Header().Set("Content-Type", "image/jpeg")
+		err = jpeg.Encode(w, img, nil)
+	case "gif":
+		w.Header().Set("Content-Type", "image/gif")
+		err = gif.Encode(w, img, nil)
+	default:
+		w.Header().Set("Content-Type", "image/png")
+		err = png.Encode(w, img)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,197 @@
+package imgproxytest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/unitedwardrobe/imgproxy-go"
+)
+
+var testConfig = imgproxy.Config{
+	SignatureSize: 15,
+	Key:           hex.EncodeToString([]byte("key")),
+	Salt:          hex.EncodeToString([]byte("salt")),
+	EncodePath:    false,
+}
+
+// infoSigner signs every URL with the same signature, starting with info.
+type infoSigner struct{}
+
+func (infoSigner) Sign(context.Context, []byte) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString("infoinfoinfoinfoinfo")
+}
+
+func Test_Server(t *testing.T) {
+	Convey("Server", t, func() {
+		server, err := NewServer(testConfig)
+		So(err, ShouldBeNil)
+		defer server.Close()
+
+		ip := server.Imgproxy()
+
+		Convey("Returns a placeholder image sized per the options", func() {
+			url, err := ip.Builder().Resize(imgproxy.ResizingTypeFit, 30, 20, false, false).Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+
+			res, err := http.Get(url)
+			So(err, ShouldBeNil)
+			defer res.Body.Close()
+			So(res.StatusCode, ShouldEqual, http.StatusOK)
+			So(res.Header.Get("Content-Type"), ShouldEqual, "image/png")
+
+			cfg, format, err := image.DecodeConfig(res.Body)
+			So(err, ShouldBeNil)
+			So(format, ShouldEqual, "png")
+			So(cfg.Width, ShouldEqual, 30)
+			So(cfg.Height, ShouldEqual, 20)
+		})
+
+		Convey("Encodes the placeholder image in the requested format", func() {
+			for _, format := range []string{"jpeg", "gif"} {
+				url, err := ip.Builder().Width(10).Format(format).Generate("my/image.jpg")
+				So(err, ShouldBeNil)
+
+				res, err := http.Get(url)
+				So(err, ShouldBeNil)
+				defer res.Body.Close()
+				So(res.Header.Get("Content-Type"), ShouldEqual, "image/"+format)
+
+				cfg, decoded, err := image.DecodeConfig(res.Body)
+				So(err, ShouldBeNil)
+				So(decoded, ShouldEqual, format)
+				So(cfg.Width, ShouldEqual, 10)
+				So(cfg.Height, ShouldEqual, 10)
+			}
+		})
+
+		Convey("Uses the default size and the dpr", func() {
+			url, err := ip.Builder().DPR(2).Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+
+			res, err := http.Get(url)
+			So(err, ShouldBeNil)
+			defer res.Body.Close()
+			So(res.Header.Get("X-Result-Width"), ShouldEqual, "200")
+			So(res.Header.Get("X-Result-Height"), ShouldEqual, "200")
+		})
+
+		Convey("Rejects invalid signatures", func() {
+			url, err := ip.Builder().Width(10).Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+
+			res, err := http.Get(strings.Replace(url, "w:10", "w:20", 1))
+			So(err, ShouldBeNil)
+			res.Body.Close()
+			So(res.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(server.Requests(), ShouldBeEmpty)
+		})
+
+		Convey("Rejects negative and too large dimensions", func() {
+			for _, data := range []*imgproxy.ImgproxyURLData{
+				ip.Builder().Width(-1),
+				ip.Builder().Width(100000).Height(100000),
+				ip.Builder().Width(MaxSize).DPR(2),
+			} {
+				url, err := data.Generate("my/image.jpg")
+				So(err, ShouldBeNil)
+
+				res, err := http.Get(url)
+				So(err, ShouldBeNil)
+				res.Body.Close()
+				So(res.StatusCode, ShouldEqual, http.StatusNotFound)
+			}
+
+			So(server.Requests(), ShouldBeEmpty)
+		})
+
+		Convey("Records the requests", func() {
+			url, err := ip.Builder().Width(10).Then().Height(20).Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+
+			res, err := http.Get(url)
+			So(err, ShouldBeNil)
+			res.Body.Close()
+
+			requests := server.Requests()
+			So(requests, ShouldHaveLength, 1)
+			So(requests[0].Source, ShouldEqual, "my/image.jpg")
+			So(requests[0].Pipelines, ShouldResemble, [][]imgproxy.Option{
+				{{Key: "w", Value: "10"}},
+				{{Key: "h", Value: "20"}},
+			})
+			So(requests[0].Width, ShouldEqual, 20)
+			So(requests[0].Height, ShouldEqual, 20)
+		})
+
+		Convey("Serves the info endpoint", func() {
+			url, err := ip.InfoBuilder().Dimensions(true).Generate("my/image.jpg")
+			So(err, ShouldBeNil)
+
+			res, err := http.Get(url)
+			So(err, ShouldBeNil)
+			defer res.Body.Close()
+
+			info, err := imgproxy.DecodeInfo(res.Body)
+			So(err, ShouldBeNil)
+			So(info.Width, ShouldEqual, DefaultSize)
+			So(info.Height, ShouldEqual, DefaultSize)
+			So(server.Requests()[0].Info, ShouldBeTrue)
+		})
+	})
+
+	Convey("Server with a signature starting with info", t, func() {
+		cfg := testConfig
+		cfg.Signer = infoSigner{}
+
+		server, err := NewServer(cfg)
+		So(err, ShouldBeNil)
+		defer server.Close()
+
+		url, err := server.Imgproxy().Builder().Width(10).Generate("my/image.jpg")
+		So(err, ShouldBeNil)
+		So(url, ShouldStartWith, server.URL+"/info")
+
+		res, err := http.Get(url)
+		So(err, ShouldBeNil)
+		res.Body.Close()
+		So(res.StatusCode, ShouldEqual, http.StatusOK)
+		So(server.Requests()[0].Info, ShouldBeFalse)
+	})
+
+	Convey("Server passes the health check", t, func() {
+		server, err := NewServer(testConfig)
+		So(err, ShouldBeNil)
+		defer server.Close()
+
+		client := imgproxy.NewClient(server.Imgproxy(), imgproxy.ClientConfig{})
+		So(client.CheckHealth(context.Background(), ""), ShouldBeNil)
+	})
+
+	Convey("Echo server", t, func() {
+		server, err := NewEchoServer(testConfig)
+		So(err, ShouldBeNil)
+		defer server.Close()
+
+		url, err := server.Imgproxy().Builder().Width(10).Generate("my/image.jpg")
+		So(err, ShouldBeNil)
+
+		res, err := http.Get(url)
+		So(err, ShouldBeNil)
+		defer res.Body.Close()
+		So(res.Header.Get("Content-Type"), ShouldEqual, "application/json")
+
+		var req Request
+		So(json.NewDecoder(res.Body).Decode(&req), ShouldBeNil)
+		So(req, ShouldResemble, server.Requests()[0])
+	})
+}
@@ -0,0 +1,201 @@
+package imgproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const infoPathPrefix = "info"
+
+// InfoURLData is a struct that contains the data required for generating an imgproxy Pro info URL.
+// The info endpoint returns the source image metadata as JSON, see Info.
+type InfoURLData struct {
+	url *ImgproxyURLData
+}
+
+// InfoBuilder returns a *InfoURLData that can be used to construct an imgproxy Pro info URL.
+func (i *Imgproxy) InfoBuilder() *InfoURLData {
+	return &InfoURLData{
+		url: i.Builder(),
+	}
+}
+
+// Generate generates the imgproxy info URL.
+func (b *InfoURLData) Generate(uri string) (string, error) {
+	return b.GenerateContext(context.Background(), uri)
+}
+
+// GenerateContext generates the imgproxy info URL, passing the context to the signer.
+func (b *InfoURLData) GenerateContext(ctx context.Context, uri string) (string, error) {
+	dst := append([]byte(b.url.baseURL(uri)), infoPathPrefix...)
+
+	url, err := b.url.appendPath(ctx, dst, uri)
+	if err != nil {
+		return "", err
+	}
+
+	return string(url), nil
+}
+
+// GeneratePath generates the signed path of the imgproxy info URL, /info/signature/options/source,
+// without base URL.
+func (b *InfoURLData) GeneratePath(uri string) (string, error) {
+	path, err := b.url.appendPath(context.Background(), []byte("/"+infoPathPrefix), uri)
+	if err != nil {
+		return "", err
+	}
+
+	return string(path), nil
+}
+
+// SetOption sets an option on the info URL.
+func (b *InfoURLData) SetOption(key, value string) *InfoURLData {
+	b.url.SetOption(key, value)
+	return b
+}
+
+// Size defines whether the size of the source file is returned.
+func (b *InfoURLData) Size(size bool) *InfoURLData {
+	return b.SetOption("s", boolAsNumberString(size))
+}
+
+// Format defines whether the format of the source image is returned.
+func (b *InfoURLData) Format(format bool) *InfoURLData {
+	return b.SetOption("f", boolAsNumberString(format))
+}
+
+// Dimensions defines whether the width and height of the source image are returned.
+func (b *InfoURLData) Dimensions(dimensions bool) *InfoURLData {
+	return b.SetOption("d", boolAsNumberString(dimensions))
+}
+
+// EXIF defines whether the EXIF metadata of the source image is returned.
+func (b *InfoURLData) EXIF(exif bool) *InfoURLData {
+	return b.SetOption("exif", boolAsNumberString(exif))
+}
+
+// IPTC defines whether the IPTC metadata of the source image is returned.
+func (b *InfoURLData) IPTC(iptc bool) *InfoURLData {
+	return b.SetOption("iptc", boolAsNumberString(iptc))
+}
+
+// VideoMeta defines whether the metadata of source videos is returned.
+func (b *InfoURLData) VideoMeta(videoMeta bool) *InfoURLData {
+	return b.SetOption("vm", boolAsNumberString(videoMeta))
+}
+
+// DetectObjects defines whether the objects detected in the source image are returned.
+func (b *InfoURLData) DetectObjects(detect bool) *InfoURLData {
+	return b.SetOption("do", boolAsNumberString(detect))
+}
+
+// Colorspace defines whether the colorspace of the source image is returned.
+func (b *InfoURLData) Colorspace(colorspace bool) *InfoURLData {
+	return b.SetOption("cs", boolAsNumberString(colorspace))
+}
+
+// Bands defines whether the number of bands of the source image is returned.
+func (b *InfoURLData) Bands(bands bool) *InfoURLData {
+	return b.SetOption("b", boolAsNumberString(bands))
+}
+
+// Palette defines the number of colors of the returned palette, between 2 and 256. 0 disables it.
+func (b *InfoURLData) Palette(colors int) *InfoURLData {
+	if colors != 0 && (colors < 2 || colors > 256) {
+		b.url.setError(errors.Wrapf(ErrInvalidOption, "palette: %d colors is out of range", colors))
+		return b
+	}
+
+	return b.SetOption("p", strconv.Itoa(colors))
+}
+
+// Average defines whether the average color of the source image is returned,
+// optionally ignoring the transparent pixels.
+func (b *InfoURLData) Average(average bool, ignoreTransparent bool) *InfoURLData {
+	return b.SetOption("avg", fmt.Sprintf(
+		"%s:%s",
+		boolAsNumberString(average),
+		boolAsNumberString(ignoreTransparent),
+	))
+}
+
+// DominantColors defines whether the dominant colors of the source image are returned.
+// When buildMissed is true, imgproxy builds the colors that are missing from the image.
+func (b *InfoURLData) DominantColors(dominantColors bool, buildMissed bool) *InfoURLData {
+	return b.SetOption("dc", fmt.Sprintf(
+		"%s:%s",
+		boolAsNumberString(dominantColors),
+		boolAsNumberString(buildMissed),
+	))
+}
+
+// Blurhash defines the number of components of the returned BlurHash, between 1 and 9. 0 disables it.
+func (b *InfoURLData) Blurhash(xComponents int, yComponents int) *InfoURLData {
+	if xComponents < 0 || xComponents > 9 || yComponents < 0 || yComponents > 9 {
+		b.url.setError(errors.Wrapf(ErrInvalidOption, "blurhash: %dx%d components is out of range", xComponents, yComponents))
+		return b
+	}
+
+	return b.SetOption("bh", fmt.Sprintf("%d:%d", xComponents, yComponents))
+}
+
+// Info holds the response of the imgproxy Pro info endpoint.
+// Fields are only set when requested with the matching InfoURLData option.
+type Info struct {
+	Size       int64                  `json:"size,omitempty"`
+	Format     string                 `json:"format,omitempty"`
+	Width      int                    `json:"width,omitempty"`
+	Height     int                    `json:"height,omitempty"`
+	EXIF       map[string]interface{} `json:"exif,omitempty"`
+	IPTC       map[string]interface{} `json:"iptc,omitempty"`
+	VideoMeta  map[string]interface{} `json:"video_meta,omitempty"`
+	Objects    []InfoObject           `json:"objects,omitempty"`
+	Colorspace string                 `json:"colorspace,omitempty"`
+	Bands      int                    `json:"bands,omitempty"`
+	Palette    []InfoColor            `json:"palette,omitempty"`
+	Average    *InfoColor             `json:"average,omitempty"`
+	// DominantColors holds colors like vibrant, vibrant_light or muted_dark.
+	DominantColors map[string]InfoColor `json:"dominant_colors,omitempty"`
+	Blurhash       string               `json:"blurhash,omitempty"`
+}
+
+// InfoColor holds a color returned by the info endpoint.
+type InfoColor struct {
+	R uint8 `json:"R"`
+	G uint8 `json:"G"`
+	B uint8 `json:"B"`
+	A uint8 `json:"A"`
+}
+
+// GetHexOption gets the color value as hexadecimal string, so it can be used as an option Color.
+func (c InfoColor) GetHexOption() string {
+	return RGBColor{R: int(c.R), G: int(c.G), B: int(c.B)}.GetHexOption()
+}
+
+// InfoObject holds an object detected in the source image.
+// The coordinates are relative to the image size, between 0 and 1.
+type InfoObject struct {
+	ClassID    int     `json:"class_id"`
+	ClassName  string  `json:"class_name"`
+	Confidence float64 `json:"confidence"`
+	Left       float64 `json:"left"`
+	Top        float64 `json:"top"`
+	Width      float64 `json:"width"`
+	Height     float64 `json:"height"`
+}
+
+// DecodeInfo decodes the JSON response of the info endpoint.
+func DecodeInfo(r io.Reader) (*Info, error) {
+	var info Info
+
+	if err := json.NewDecoder(r).Decode(&info); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &info, nil
+}
@@ -0,0 +1,253 @@
+package imgproxy
+
+import (
+	"strconv"
+	"strings"
+)
+
+// optionAliases maps the full option names to the short ones used by the builder.
+var optionAliases = map[string]string{
+	"resize":                         "rs",
+	"size":                           "s",
+	"resizing_type":                  "rt",
+	"resizing_algorithm":             "ra",
+	"width":                          "w",
+	"height":                         "h",
+	"enlarge":                        "el",
+	"extend":                         "ex",
+	"gravity":                        "g",
+	"crop":                           "c",
+	"trim":                           "t",
+	"padding":                        "pd",
+	"auto_rotate":                    "ar",
+	"rotate":                         "rot",
+	"flip":                           "fl",
+	"background":                     "bg",
+	"adjust":                         "a",
+	"brightness":                     "br",
+	"contrast":                       "co",
+	"saturation":                     "sa",
+	"monochrome":                     "mc",
+	"duotone":                        "dt",
+	"blur":                           "bl",
+	"sharpen":                        "sh",
+	"unsharp_masking":                "ush",
+	"pixelate":                       "pix",
+	"watermark":                      "wm",
+	"watermark_url":                  "wmu",
+	"watermark_text":                 "wmt",
+	"watermark_size":                 "wms",
+	"watermark_rotate":               "wmr",
+	"watermark_shadow":               "wmsh",
+	"strip_metadata":                 "sm",
+	"keep_copyright":                 "kcr",
+	"strip_color_profile":            "scp",
+	"enforce_thumbnail":              "eth",
+	"quality":                        "q",
+	"max_bytes":                      "mb",
+	"format":                         "f",
+	"ext":                            "f",
+	"page":                           "pg",
+	"pages":                          "pgs",
+	"disable_animation":              "da",
+	"video_thumbnail_second":         "vts",
+	"video_thumbnail_keyframes":      "vtk",
+	"video_thumbnail_tile":           "vtt",
+	"video_thumbnail_animation":      "vta",
+	"fallback_image_url":             "fiu",
+	"skip_processing":                "skp",
+	"cachebuster":                    "cb",
+	"expires":                        "exp",
+	"filename":                       "fn",
+	"return_attachment":              "att",
+	"preset":                         "pr",
+	"max_src_resolution":             "msr",
+	"max_src_file_size":              "msfs",
+	"max_animation_frames":           "maf",
+	"max_animation_frame_resolution": "mafr",
+	"max_result_dimension":           "mrd",
+}
+
+// defaultOptionValues holds the options values that are imgproxy defaults regardless of its configuration.
+var defaultOptionValues = map[string]string{
+	"dpr": "1",
+	"rot": "0",
+	"bl":  "0",
+	"sh":  "0",
+	"pix": "0",
+}
+
+// boolOptions holds the options taking a single boolean argument.
+var boolOptions = map[string]bool{
+	"el": true, "ar": true, "da": true, "vtk": true, "raw": true, "att": true,
+	"sm": true, "kcr": true, "scp": true, "eth": true,
+}
+
+// Normalize returns a copy of the URL data where semantically equivalent options are collapsed into
+// a single canonical form, so equivalent URLs get the same signature:
+//   - full option names are replaced by their short names;
+//   - rs, s, rt, w, h, el and ex are merged, in URL order, into a single rs option without trailing defaults;
+//   - boolean arguments are written as 1 or 0;
+//   - options set to their imgproxy default value are dropped.
+//
+// Pipelines with a preset only get the first and third steps, as explicit default values override the preset.
+//
+// It works both on builders and on parsed URLs. Options it can't interpret are kept as they are.
+func (i *ImgproxyURLData) Normalize() *ImgproxyURLData {
+	normalized := i.Builder()
+	normalized.sourceFilename = i.sourceFilename
+	normalized.err = i.err
+
+	// imgproxy applies the options in URL order, so they are merged in the order Generate writes them.
+	for j, p := range i.currentPipelines() {
+		if j > 0 {
+			normalized.Then()
+		}
+
+		normalizePipeline(normalized, p.list(i.cfg.OptionOrder))
+	}
+
+	return normalized
+}
+
+func normalizePipeline(data *ImgproxyURLData, options []Option) {
+	var preset bool
+
+	for j, option := range options {
+		if alias, ok := optionAliases[option.Key]; ok {
+			options[j].Key = alias
+		}
+
+		preset = preset || options[j].Key == "pr"
+	}
+
+	// With a preset, explicit default values override the preset values, and the missing resize
+	// arguments are the preset ones, so neither the defaults nor the resize options can be dropped.
+	var resize string
+	var resizeAt int
+	var ok bool
+
+	if !preset {
+		resize, resizeAt, ok = foldResizeOptions(options)
+	}
+
+	for j, option := range options {
+		if ok && isResizeOption(option.Key) {
+			if j == resizeAt && resize != "" {
+				data.SetOption("rs", resize)
+			}
+
+			continue
+		}
+
+		if boolOptions[option.Key] {
+			if value, ok := normalizeBool(option.Value); ok {
+				option.Value = value
+			}
+		}
+
+		if value, ok := defaultOptionValues[option.Key]; ok && value == option.Value && !preset {
+			continue
+		}
+
+		data.SetOption(option.Key, option.Value)
+	}
+}
+
+func isResizeOption(key string) bool {
+	switch key {
+	case "rs", "s", "rt", "w", "h", "el", "ex":
+		return true
+	}
+
+	return false
+}
+
+// foldResizeOptions merges the resize related options, in order, into a single rs value.
+// It returns the index of the first resize option, and false when the options can't be merged safely.
+func foldResizeOptions(options []Option) (string, int, bool) {
+	// Resizing type, width, height, enlarge and extend, as in the rs option.
+	resize := []string{string(ResizingTypeFit), "0", "0", "0", "0"}
+	defaults := append([]string(nil), resize...)
+	first := -1
+
+	for j, option := range options {
+		var args []string
+		var offset int
+
+		switch option.Key {
+		case "rs":
+			args = strings.Split(option.Value, ":")
+		case "s":
+			args, offset = strings.Split(option.Value, ":"), 1
+		case "rt":
+			args = []string{option.Value}
+		case "w":
+			args, offset = []string{option.Value}, 1
+		case "h":
+			args, offset = []string{option.Value}, 2
+		case "el":
+			args, offset = []string{option.Value}, 3
+		case "ex":
+			args, offset = []string{option.Value}, 4
+		default:
+			continue
+		}
+
+		if first < 0 {
+			first = j
+		}
+
+		if offset+len(args) > len(resize) {
+			return "", first, false
+		}
+
+		for k, arg := range args {
+			if arg == "" {
+				// imgproxy keeps the previous value for empty arguments.
+				continue
+			}
+
+			value, ok := normalizeResizeArg(offset+k, arg)
+			if !ok {
+				return "", first, false
+			}
+
+			resize[offset+k] = value
+		}
+	}
+
+	end := len(resize)
+	for end > 0 && resize[end-1] == defaults[end-1] {
+		end--
+	}
+
+	return strings.Join(resize[:end], ":"), first, true
+}
+
+func normalizeResizeArg(position int, arg string) (string, bool) {
+	switch position {
+	case 0:
+		return arg, true
+	case 1, 2:
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return "", false
+		}
+
+		return strconv.Itoa(n), true
+	default:
+		return normalizeBool(arg)
+	}
+}
+
+func normalizeBool(value string) (string, bool) {
+	switch value {
+	case "1", "t", "true":
+		return "1", true
+	case "0", "f", "false":
+		return "0", true
+	}
+
+	return "", false
+}
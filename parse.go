@@ -0,0 +1,133 @@
+package imgproxy
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Parse parses an imgproxy URL generated with the same configuration.
+// It verifies the signature and returns the options as a *ImgproxyURLData along with the source URI.
+// The query and fragment are ignored, like imgproxy does.
+//
+// The source extension is returned as an f option, so generating the URL from the returned data
+// gives an URL with f:extension instead, which is different but processed the same way.
+func (i *Imgproxy) Parse(rawURL string) (*ImgproxyURLData, string, error) {
+	path := rawURL
+	for _, baseURL := range i.baseURLs {
+		if strings.HasPrefix(rawURL, baseURL) {
+			path = rawURL[len(baseURL)-1:]
+
+			if end := strings.IndexAny(path, "?#"); end >= 0 {
+				path = path[:end]
+			}
+
+			break
+		}
+	}
+
+	if path == rawURL {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, "", errors.Wrap(ErrInvalidURL, err.Error())
+		}
+
+		path = u.EscapedPath()
+	}
+
+	return i.ParsePath(path)
+}
+
+// ParsePath parses the path of an imgproxy URL, starting with the signature.
+// It verifies the signature and returns the options as a *ImgproxyURLData along with the source URI.
+// Like Parse, it returns the source extension as an f option.
+func (i *Imgproxy) ParsePath(path string) (*ImgproxyURLData, string, error) {
+	signature, uriWithOptions, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return nil, "", errors.Wrapf(ErrInvalidURL, "%q has no source", path)
+	}
+
+	uriWithOptions = "/" + uriWithOptions
+
+	expected, err := i.sign(context.Background(), uriWithOptions)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return nil, "", errors.WithStack(ErrSignatureMismatch)
+	}
+
+	data := i.Builder()
+	segments := strings.Split(uriWithOptions[1:], "/")
+
+	for j, segment := range segments {
+		switch {
+		case segment == plainSourcePrefix:
+			return i.parsePlainSource(data, strings.Join(segments[j+1:], "/"))
+
+		case segment == pipelineSeparator:
+			data.Then()
+
+		case strings.Contains(segment, ":"):
+			key, value, _ := strings.Cut(segment, ":")
+			data.SetOption(key, value)
+
+		default:
+			return i.parseEncodedSource(data, segments[j:])
+		}
+	}
+
+	return nil, "", errors.Wrapf(ErrInvalidURL, "%q has no source", path)
+}
+
+// parsePlainSource parses a plain source, where the extension follows the last @.
+// The source is percent-decoded unless the configuration keeps plain sources unescaped.
+func (i *Imgproxy) parsePlainSource(data *ImgproxyURLData, source string) (*ImgproxyURLData, string, error) {
+	if at := strings.LastIndex(source, "@"); at >= 0 {
+		data.Format(source[at+1:])
+		source = source[:at]
+	}
+
+	if !i.cfg.UnescapedPlainSources {
+		unescaped, err := url.PathUnescape(source)
+		if err != nil {
+			return nil, "", errors.Wrap(ErrInvalidURL, err.Error())
+		}
+
+		source = unescaped
+	}
+
+	if source == "" {
+		return nil, "", errors.Wrap(ErrInvalidURL, "empty plain source")
+	}
+
+	return data, source, nil
+}
+
+// parseEncodedSource parses a base64 source, which may be split in several segments
+// and followed by a filename. The extension follows the last dot.
+func (i *Imgproxy) parseEncodedSource(data *ImgproxyURLData, segments []string) (*ImgproxyURLData, string, error) {
+	if i.cfg.Base64URLIncludesFilename && len(segments) > 1 {
+		data.sourceFilename = segments[len(segments)-1]
+		segments = segments[:len(segments)-1]
+	}
+
+	source := strings.Join(segments, "")
+
+	if dot := strings.LastIndex(source, "."); dot >= 0 {
+		data.Format(source[dot+1:])
+		source = source[:dot]
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(source, "="))
+	if err != nil {
+		return nil, "", errors.Wrap(ErrInvalidURL, err.Error())
+	}
+
+	return data, string(decoded), nil
+}
@@ -0,0 +1,131 @@
+package imgproxy
+
+// pipeline holds the options of a processing pipeline along with their insertion order.
+type pipeline struct {
+	options map[string]string
+	keys    []string
+}
+
+func newPipeline() *pipeline {
+	return &pipeline{
+		options: make(map[string]string, 0),
+	}
+}
+
+func (p *pipeline) set(key, value string) {
+	if _, ok := p.options[key]; !ok {
+		p.keys = append(p.keys, key)
+	}
+
+	p.options[key] = value
+}
+
+// list returns the options in the given order.
+func (p *pipeline) list(order OptionOrder) []Option {
+	keys := p.appendKeys(nil, order)
+	options := make([]Option, len(keys))
+
+	for j, key := range keys {
+		options[j] = Option{Key: key, Value: p.options[key]}
+	}
+
+	return options
+}
+
+// canonicalOptionRanks holds the position of each option in the imgproxy processing order.
+var canonicalOptionRanks = func() map[string]int {
+	keys := []string{
+		"pr",
+		"rs", "s", "rt", "ra", "w", "h", "mw", "mh", "z", "dpr", "el", "ex", "exar",
+		"g", "c", "t", "pd", "ar", "rot", "fl", "bg", "bga",
+		"a", "br", "co", "sa", "mc", "dt", "bl", "sh", "ush", "pix", "bd", "dd", "col", "gr",
+		"wm", "wmu", "wmt", "wms", "wmr", "wmsh", "st",
+		"sm", "kcr", "dpi", "scp", "eth",
+		"q", "fq", "aq", "mb", "jpgo", "pngo", "webpo", "f",
+		"pg", "pgs", "da", "vts", "vtk", "vtt", "vta",
+		"fiu", "skp", "raw", "cb", "exp", "fn", "att", "hs",
+		"msr", "msfs", "maf", "mafr", "mrd",
+	}
+
+	ranks := make(map[string]int, len(keys))
+	for j, key := range keys {
+		ranks[key] = j
+	}
+
+	return ranks
+}()
+
+// appendKeys appends the option keys to keys in the given order.
+// With OptionOrderInsertion, keys added to the options map directly are appended in alphabetical order.
+// It doesn't allocate when keys has enough capacity, so it can be used with a stack buffer.
+func (p *pipeline) appendKeys(keys []string, order OptionOrder) []string {
+	start := len(keys)
+
+	if order != OptionOrderInsertion {
+		for key := range p.options {
+			keys = append(keys, key)
+		}
+
+		if order == OptionOrderCanonical {
+			sortKeys(keys[start:], lessCanonical)
+		} else {
+			sortKeys(keys[start:], lessAlphabetical)
+		}
+
+		return keys
+	}
+
+	for _, key := range p.keys {
+		if _, ok := p.options[key]; ok && !containsKey(keys[start:], key) {
+			keys = append(keys, key)
+		}
+	}
+
+	inserted := len(keys)
+	for key := range p.options {
+		if !containsKey(keys[start:inserted], key) {
+			keys = append(keys, key)
+		}
+	}
+	sortKeys(keys[inserted:], lessAlphabetical)
+
+	return keys
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lessAlphabetical(a, b string) bool {
+	return a < b
+}
+
+// lessCanonical orders keys by imgproxy processing order, then unknown keys alphabetically.
+func lessCanonical(a, b string) bool {
+	rankA, okA := canonicalOptionRanks[a]
+	rankB, okB := canonicalOptionRanks[b]
+
+	switch {
+	case okA && okB:
+		return rankA < rankB
+	case okA != okB:
+		return okA
+	default:
+		return a < b
+	}
+}
+
+// sortKeys is an insertion sort, which is allocation free and fast for the few options of a URL.
+func sortKeys(keys []string, less func(a, b string) bool) {
+	for j := 1; j < len(keys); j++ {
+		for k := j; k > 0 && less(keys[k], keys[k-1]); k-- {
+			keys[k], keys[k-1] = keys[k-1], keys[k]
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package imgproxy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"hash"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Signer computes the signature of imgproxy URL paths.
+// Implement it to sign URLs with a key that is not available to the application, like in a KMS.
+// Signers must be safe for concurrent use.
+type Signer interface {
+	// Sign returns the signature of the payload, which is truncated to Config.SignatureSize bytes.
+	// The payload is a copy owned by the signer, which may retain it.
+	Sign(ctx context.Context, payload []byte) ([]byte, error)
+}
+
+// HMACSigner signs payloads with HMAC-SHA256 of the salt followed by the payload, like imgproxy does.
+type HMACSigner struct {
+	Key  []byte
+	Salt []byte
+}
+
+// Sign returns the HMAC-SHA256 signature of the payload.
+func (s HMACSigner) Sign(_ context.Context, payload []byte) ([]byte, error) {
+	signature := hmac.New(sha256.New, s.Key)
+
+	if _, err := signature.Write(s.Salt); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if _, err := signature.Write(payload); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return signature.Sum(nil), nil
+}
+
+// keyedHMAC is the default HMAC-SHA256 signer. It keeps the hash states after writing the padded key
+// and the salt, so signing only hashes the payload, and pools the hashes to avoid allocations.
+type keyedHMAC struct {
+	inner []byte
+	outer []byte
+	pool  sync.Pool
+}
+
+type hmacHashes struct {
+	inner hash.Hash
+	outer hash.Hash
+	sum   [sha256.Size]byte
+}
+
+func newKeyedHMAC(key []byte, salt []byte) (*keyedHMAC, error) {
+	if len(key) > sha256.BlockSize {
+		sum := sha256.Sum256(key)
+		key = sum[:]
+	}
+
+	ipad := make([]byte, sha256.BlockSize)
+	opad := make([]byte, sha256.BlockSize)
+	copy(ipad, key)
+	copy(opad, key)
+
+	for j := range ipad {
+		ipad[j] ^= 0x36
+		opad[j] ^= 0x5c
+	}
+
+	inner := sha256.New()
+	inner.Write(ipad)
+	inner.Write(salt)
+
+	outer := sha256.New()
+	outer.Write(opad)
+
+	innerState, err := inner.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	outerState, err := outer.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	k := &keyedHMAC{
+		inner: innerState,
+		outer: outerState,
+	}
+	k.pool.New = func() interface{} {
+		return &hmacHashes{
+			inner: sha256.New(),
+			outer: sha256.New(),
+		}
+	}
+
+	return k, nil
+}
+
+// Sign returns the HMAC-SHA256 signature of the payload.
+func (k *keyedHMAC) Sign(_ context.Context, payload []byte) ([]byte, error) {
+	h, err := k.sum(payload)
+	if err != nil {
+		return nil, err
+	}
+	defer k.pool.Put(h)
+
+	return append([]byte(nil), h.sum[:]...), nil
+}
+
+// sum computes the signature of the payload into the returned hashes, which must be put back in the pool.
+func (k *keyedHMAC) sum(payload []byte) (*hmacHashes, error) {
+	h := k.pool.Get().(*hmacHashes)
+
+	if err := h.inner.(encoding.BinaryUnmarshaler).UnmarshalBinary(k.inner); err != nil {
+		k.pool.Put(h)
+		return nil, errors.WithStack(err)
+	}
+
+	if err := h.outer.(encoding.BinaryUnmarshaler).UnmarshalBinary(k.outer); err != nil {
+		k.pool.Put(h)
+		return nil, errors.WithStack(err)
+	}
+
+	h.inner.Write(payload)
+	h.outer.Write(h.inner.Sum(h.sum[:0]))
+	h.outer.Sum(h.sum[:0])
+
+	return h, nil
+}
+
+// signatureLen returns the length of the encoded signature.
+func (i *Imgproxy) signatureLen() int {
+	if i.signer == nil {
+		return len(insecureSignature)
+	}
+
+	return base64.RawURLEncoding.EncodedLen(i.cfg.SignatureSize)
+}
+
+// signInto writes the encoded signature of path into dst, which must be signatureLen long.
+func (i *Imgproxy) signInto(ctx context.Context, dst []byte, path []byte) error {
+	switch signer := i.signer.(type) {
+	case nil:
+		copy(dst, insecureSignature)
+
+	case *keyedHMAC:
+		h, err := signer.sum(path)
+		if err != nil {
+			return err
+		}
+
+		base64.RawURLEncoding.Encode(dst, h.sum[:i.cfg.SignatureSize])
+		signer.pool.Put(h)
+
+	default:
+		// path is a slice of a pooled buffer, which is reused once the URL is generated.
+		signature, err := signer.Sign(ctx, append([]byte(nil), path...))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if len(signature) < i.cfg.SignatureSize {
+			return errors.Wrapf(ErrInvalidSignature, "signer returned %d bytes", len(signature))
+		}
+
+		base64.RawURLEncoding.Encode(dst, signature[:i.cfg.SignatureSize])
+	}
+
+	return nil
+}
+
+// sign returns the encoded signature of the given path.
+func (i *Imgproxy) sign(ctx context.Context, path string) (string, error) {
+	signature := make([]byte, i.signatureLen())
+
+	if err := i.signInto(ctx, signature, []byte(path)); err != nil {
+		return "", err
+	}
+
+	return string(signature), nil
+}
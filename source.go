@@ -0,0 +1,106 @@
+package imgproxy
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// Source is an image source imgproxy can fetch.
+type Source interface {
+	// SourceURL returns the URL of the source, as imgproxy fetches it.
+	SourceURL() string
+}
+
+type sourceURL string
+
+// SourceURL returns the URL of the source.
+func (s sourceURL) SourceURL() string {
+	return string(s)
+}
+
+// HTTPSource returns a source fetched over HTTP(S). The URL must already be a valid URL.
+func HTTPSource(rawURL string) Source {
+	return sourceURL(rawURL)
+}
+
+// S3Source returns a source fetched from Amazon S3. The versionID is optional.
+func S3Source(bucket string, key string, versionID string) Source {
+	return sourceURL("s3://" + bucket + "/" + escapeObjectKey(key) + queryString(versionID))
+}
+
+// GCSSource returns a source fetched from Google Cloud Storage. The generation is optional.
+func GCSSource(bucket string, key string, generation string) Source {
+	return sourceURL("gs://" + bucket + "/" + escapeObjectKey(key) + queryString(generation))
+}
+
+// ABSSource returns a source fetched from Azure Blob Storage.
+func ABSSource(container string, blob string) Source {
+	return sourceURL("abs://" + container + "/" + escapeObjectKey(blob))
+}
+
+// SwiftSource returns a source fetched from OpenStack Object Storage (Swift).
+func SwiftSource(container string, object string) Source {
+	return sourceURL("swift://" + container + "/" + escapeObjectKey(object))
+}
+
+// LocalSource returns a source read from the imgproxy local filesystem root.
+func LocalSource(path string) Source {
+	return sourceURL("local:///" + escapeObjectKey(strings.TrimPrefix(path, "/")))
+}
+
+// escapeObjectKey escapes each segment of a slash separated object key.
+func escapeObjectKey(key string) string {
+	segments := strings.Split(key, "/")
+	for j, segment := range segments {
+		segments[j] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func queryString(query string) string {
+	if query == "" {
+		return ""
+	}
+
+	return "?" + url.QueryEscape(query)
+}
+
+// GenerateSource generates the imgproxy URL of the source.
+func (i *ImgproxyURLData) GenerateSource(src Source) (string, error) {
+	return i.GenerateSourceContext(context.Background(), src)
+}
+
+// GenerateSourceContext generates the imgproxy URL of the source, passing the context to the signer.
+func (i *ImgproxyURLData) GenerateSourceContext(ctx context.Context, src Source) (string, error) {
+	return i.GenerateContext(ctx, src.SourceURL())
+}
+
+// appendEscapedPlainSource appends the source, percent-encoding the characters imgproxy would misread
+// in a plain source: % (escape), ? and # (query and fragment of the imgproxy URL), @ (extension separator),
+// spaces, control and non-ASCII characters. imgproxy decodes plain sources symmetrically.
+func appendEscapedPlainSource(dst []byte, source string) []byte {
+	const hex = "0123456789ABCDEF"
+
+	for j := 0; j < len(source); j++ {
+		c := source[j]
+
+		if shouldEscapePlainSource(c) {
+			dst = append(dst, '%', hex[c>>4], hex[c&15])
+		} else {
+			dst = append(dst, c)
+		}
+	}
+
+	return dst
+}
+
+func shouldEscapePlainSource(c byte) bool {
+	switch c {
+	case '%', '?', '#', '@', ' ':
+		return true
+	}
+
+	return c < 0x20 || c >= 0x7f
+}
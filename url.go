@@ -1,13 +1,15 @@
 package imgproxy
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
+	"context"
 	"encoding/base64"
 	"fmt"
-	"sort"
+	"net/url"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -15,60 +17,267 @@ import (
 // ImgproxyURLData is a struct that contains the data required for generating an imgproxy URL.
 type ImgproxyURLData struct {
 	*Imgproxy
-	Options map[string]string
+	// Options holds the options of the current pipeline, see Then.
+	Options        map[string]string
+	pipelines      []*pipeline
+	sourceFilename string
+	err            error
 }
 
-const insecureSignature = "insecure"
+// Option holds a single processing option.
+type Option struct {
+	Key   string
+	Value string
+}
+
+const (
+	insecureSignature = "insecure"
+	pipelineSeparator = "-"
+	plainSourcePrefix = "plain"
+
+	// signaturePlaceholder is at least as long as the longest encoded signature and source chunk.
+	signaturePlaceholder = "0000000000000000000000000000000000000000000000000000000000000000"
+
+	maxPooledBufferSize = 64 << 10
+)
+
+// currentPipelines returns the pipelines, the last one being the one options are added to.
+// It also supports ImgproxyURLData values built without Builder, and Options being reassigned,
+// in which case the last pipeline is rebound to the new map.
+func (i *ImgproxyURLData) currentPipelines() []*pipeline {
+	if i.Options == nil {
+		i.Options = make(map[string]string, 0)
+	}
+
+	if len(i.pipelines) == 0 {
+		i.pipelines = []*pipeline{{options: i.Options}}
+		return i.pipelines
+	}
+
+	if last := i.pipelines[len(i.pipelines)-1]; !sameMap(last.options, i.Options) {
+		last.options = i.Options
+		last.keys = nil
+	}
+
+	return i.pipelines
+}
+
+// sameMap returns whether a and b are the same map.
+func sameMap(a, b map[string]string) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// Then starts a new processing pipeline (imgproxy Pro).
+// The following options apply to the result of the previous pipelines.
+func (i *ImgproxyURLData) Then() *ImgproxyURLData {
+	p := newPipeline()
+	i.pipelines = append(i.currentPipelines(), p)
+	i.Options = p.options
+
+	return i
+}
+
+// Pipelines returns the options of each processing pipeline in insertion order.
+func (i *ImgproxyURLData) Pipelines() [][]Option {
+	pipelines := make([][]Option, len(i.currentPipelines()))
+
+	for j, p := range i.pipelines {
+		pipelines[j] = p.list(OptionOrderInsertion)
+	}
+
+	return pipelines
+}
+
+// urlBufferPool holds the buffers Generate builds URLs into.
+var urlBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
+}
 
 // Generate generates the imgproxy URL.
 func (i *ImgproxyURLData) Generate(uri string) (string, error) {
-	if i.cfg.EncodePath {
-		uri = base64.RawStdEncoding.EncodeToString([]byte(uri))
-	} else {
-		uri = "plain/" + uri
-	}
+	return i.GenerateContext(context.Background(), uri)
+}
+
+// GenerateContext generates the imgproxy URL, passing the context to the signer.
+func (i *ImgproxyURLData) GenerateContext(ctx context.Context, uri string) (string, error) {
+	buf := urlBufferPool.Get().(*[]byte)
 
-	keys := make([]string, len(i.Options))
-	j := 0
-	for key := range i.Options {
-		keys[j] = key
-		j++
+	url, err := i.appendURL(ctx, (*buf)[:0], uri)
+	if err != nil {
+		urlBufferPool.Put(buf)
+		return "", err
 	}
-	sort.Strings(keys)
 
-	options := "/"
-	for _, key := range keys {
-		options += key + ":" + i.Options[key] + "/"
+	result := string(url)
+
+	if cap(url) <= maxPooledBufferSize {
+		*buf = url
+		urlBufferPool.Put(buf)
 	}
 
-	uriWithOptions := options + uri
+	return result, nil
+}
 
-	if len(i.salt) == 0 && len(i.key) == 0 {
-		return i.cfg.BaseURL + insecureSignature + uriWithOptions, nil
+// AppendURL appends the imgproxy URL to dst and returns the extended buffer.
+// When dst has enough capacity and the default signer is used, it doesn't allocate.
+func (i *ImgproxyURLData) AppendURL(dst []byte, uri string) ([]byte, error) {
+	return i.appendURL(context.Background(), dst, uri)
+}
+
+func (i *ImgproxyURLData) appendURL(ctx context.Context, dst []byte, uri string) ([]byte, error) {
+	if i.err != nil {
+		return dst, i.err
 	}
 
-	signature, err := getSignatureHash(i.key, i.salt, i.cfg.SignatureSize, uriWithOptions)
+	start := len(dst)
+	dst = append(dst, i.baseURL(uri)...)
+	dst, signatureAt := i.appendURLStart(dst)
+	dst = i.appendOptionsPath(dst)
+
+	return i.appendURLEnd(ctx, dst, start, signatureAt, uri)
+}
+
+// GeneratePath generates the signed path of the imgproxy URL, /signature/options/source, without base URL.
+func (i *ImgproxyURLData) GeneratePath(uri string) (string, error) {
+	path, err := i.appendPath(context.Background(), nil, uri)
 	if err != nil {
 		return "", err
 	}
 
-	return i.cfg.BaseURL + signature + uriWithOptions, nil
+	return string(path), nil
 }
 
-func getSignatureHash(key []byte, salt []byte, signatureSize int, payload string) (string, error) {
-	signature := hmac.New(sha256.New, key)
+// GenerateSignature generates the signature of the imgproxy URL and the signed path, /options/source,
+// which are joined by GeneratePath.
+func (i *ImgproxyURLData) GenerateSignature(uri string) (signature string, path string, err error) {
+	signedPath, err := i.appendPath(context.Background(), nil, uri)
+	if err != nil {
+		return "", "", err
+	}
+
+	signatureEnd := 1 + i.signatureLen()
+
+	return string(signedPath[1:signatureEnd]), string(signedPath[signatureEnd:]), nil
+}
+
+func (i *ImgproxyURLData) appendPath(ctx context.Context, dst []byte, uri string) ([]byte, error) {
+	if i.err != nil {
+		return dst, i.err
+	}
+
+	start := len(dst)
+	dst, signatureAt := i.appendURLStart(append(dst, '/'))
+	dst = i.appendOptionsPath(dst)
+
+	return i.appendURLEnd(ctx, dst, start, signatureAt, uri)
+}
+
+// appendURLStart appends a placeholder for the signature and the leading slash of the signed path.
+// It returns the extended buffer and the position of the signature.
+func (i *ImgproxyURLData) appendURLStart(dst []byte) ([]byte, int) {
+	// The signature is written in place by appendURLEnd once the path is known.
+	signatureAt := len(dst)
+	dst = append(dst, signaturePlaceholder[:i.signatureLen()]...)
+
+	return append(dst, '/'), signatureAt
+}
+
+// appendURLEnd appends the source and signs the path. On error, dst is truncated to start.
+func (i *ImgproxyURLData) appendURLEnd(ctx context.Context, dst []byte, start int, signatureAt int, uri string) ([]byte, error) {
+	dst = i.appendSource(dst, uri)
+	pathAt := signatureAt + i.signatureLen()
+
+	if err := i.signInto(ctx, dst[signatureAt:pathAt], dst[pathAt:]); err != nil {
+		return dst[:start], err
+	}
+
+	return dst, nil
+}
+
+// appendOptionsPath appends the options of all the pipelines, each followed by a slash.
+// Empty pipelines are skipped.
+func (i *ImgproxyURLData) appendOptionsPath(dst []byte) []byte {
+	var buf [32]string
+	first := true
+
+	for _, p := range i.currentPipelines() {
+		if len(p.options) == 0 {
+			continue
+		}
+
+		if !first {
+			dst = append(dst, pipelineSeparator+"/"...)
+		}
+		first = false
+
+		for _, key := range p.appendKeys(buf[:0], i.cfg.OptionOrder) {
+			dst = append(dst, key...)
+			dst = append(dst, ':')
+			dst = append(dst, p.options[key]...)
+			dst = append(dst, '/')
+		}
+	}
+
+	return dst
+}
+
+// appendSource appends the plain or base64 encoded source.
+func (i *ImgproxyURLData) appendSource(dst []byte, uri string) []byte {
+	if !i.cfg.EncodePath {
+		dst = append(dst, plainSourcePrefix+"/"...)
+
+		if i.cfg.UnescapedPlainSources {
+			return append(dst, uri...)
+		}
 
-	if _, err := signature.Write(salt); err != nil {
-		return "", errors.WithStack(err)
+		return appendEscapedPlainSource(dst, uri)
 	}
 
-	if _, err := signature.Write([]byte(payload)); err != nil {
-		return "", errors.WithStack(err)
+	// Encoding chunks whose size is a multiple of 3 avoids copying uri to a []byte.
+	var chunk [48]byte
+
+	for len(uri) > 0 {
+		n := copy(chunk[:], uri)
+		uri = uri[n:]
+
+		at := len(dst)
+		dst = append(dst, signaturePlaceholder[:base64.RawURLEncoding.EncodedLen(n)]...)
+		base64.RawURLEncoding.Encode(dst[at:], chunk[:n])
 	}
 
-	sha := base64.RawURLEncoding.EncodeToString(signature.Sum(nil)[:signatureSize])
+	if i.cfg.Base64URLIncludesFilename && i.sourceFilename != "" {
+		dst = append(dst, '/')
+		dst = append(dst, i.sourceFilename...)
+	}
 
-	return sha, nil
+	return dst
+}
+
+// SourceFilename appends a human-readable filename to base64 encoded sources, e.g. /<base64>/puppy.jpg.
+// The filename is sanitized to letters, digits, dots, dashes and underscores.
+// It's only used when both EncodePath and Base64URLIncludesFilename are set in the Config,
+// since imgproxy would otherwise read it as part of the source.
+func (i *ImgproxyURLData) SourceFilename(filename string) *ImgproxyURLData {
+	i.sourceFilename = sanitizeFilename(filename)
+	return i
+}
+
+// sanitizeFilename replaces the characters that are not safe in a path segment with dashes.
+func sanitizeFilename(filename string) string {
+	sanitized := []byte(filename)
+
+	for j, c := range sanitized {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-', c == '_':
+		default:
+			sanitized[j] = '-'
+		}
+	}
+
+	return strings.Trim(string(sanitized), ".")
 }
 
 // ResizingType enum.
@@ -240,7 +449,7 @@ func (h HexColor) SetBgOption(i *ImgproxyURLData) *ImgproxyURLData {
 	return i.SetOption("bg", string(h))
 }
 
-// RGBColor holds an RGB color.
+// RGBColor holds an RGB color. The channels must be between 0 and 255.
 type RGBColor struct {
 	R int
 	G int
@@ -249,9 +458,42 @@ type RGBColor struct {
 
 // SetBgOption sets the background option.
 func (rgb RGBColor) SetBgOption(i *ImgproxyURLData) *ImgproxyURLData {
+	if err := checkColor("background", rgb); err != nil {
+		return i.setError(err)
+	}
+
 	return i.SetOption("bg", fmt.Sprintf("%d:%d:%d", rgb.R, rgb.G, rgb.B))
 }
 
+// GetHexOption gets the color value as hexadecimal string without the leading #.
+func (h HexColor) GetHexOption() string {
+	return strings.TrimPrefix(string(h), "#")
+}
+
+// GetHexOption gets the color value as hexadecimal string.
+func (rgb RGBColor) GetHexOption() string {
+	return fmt.Sprintf("%02x%02x%02x", rgb.R, rgb.G, rgb.B)
+}
+
+// valid returns whether the channels are between 0 and 255.
+func (rgb RGBColor) valid() bool {
+	return rgb.R >= 0 && rgb.R <= 255 && rgb.G >= 0 && rgb.G <= 255 && rgb.B >= 0 && rgb.B <= 255
+}
+
+// checkColor returns an ErrInvalidOption error when the channels of an RGBColor are out of range.
+func checkColor(option string, color Color) error {
+	if rgb, ok := color.(RGBColor); ok && !rgb.valid() {
+		return errors.Wrapf(ErrInvalidOption, "%s: color %d:%d:%d is out of range", option, rgb.R, rgb.G, rgb.B)
+	}
+
+	return nil
+}
+
+// Color interface to get a color as an hexadecimal option value.
+type Color interface {
+	GetHexOption() string
+}
+
 // BackgroundSetter interface to set the background option.
 type BackgroundSetter interface {
 	SetBgOption(*ImgproxyURLData) *ImgproxyURLData
@@ -277,6 +519,179 @@ func (i *ImgproxyURLData) Sharpen(sigma int) *ImgproxyURLData {
 	return i.SetOption("sh", strconv.Itoa(sigma))
 }
 
+// BlurFloat is the same as Blur but accepts a fractional sigma.
+func (i *ImgproxyURLData) BlurFloat(sigma float64) *ImgproxyURLData {
+	if sigma < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "blur: sigma %v is negative", sigma))
+	}
+
+	return i.SetOption("bl", floatAsString(sigma))
+}
+
+// SharpenFloat is the same as Sharpen but accepts a fractional sigma.
+func (i *ImgproxyURLData) SharpenFloat(sigma float64) *ImgproxyURLData {
+	if sigma < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "sharpen: sigma %v is negative", sigma))
+	}
+
+	return i.SetOption("sh", floatAsString(sigma))
+}
+
+// UnsharpMaskingMode holds an unsharp masking mode option value.
+type UnsharpMaskingMode string
+
+// UnsharpMaskingMode constants.
+const (
+	// Apply unsharp masking only when the image is downscaled and the sharpen option is not set.
+	UnsharpMaskingModeAuto = UnsharpMaskingMode("auto")
+	// Never apply unsharp masking.
+	UnsharpMaskingModeNone = UnsharpMaskingMode("none")
+	// Always apply unsharp masking.
+	UnsharpMaskingModeAlways = UnsharpMaskingMode("always")
+)
+
+// UnsharpMaskingOptions holds the unsharp masking parameters.
+// Zero values are omitted from the URL so imgproxy uses its configured defaults.
+type UnsharpMaskingOptions struct {
+	Mode UnsharpMaskingMode
+	// Weight of the sharpening mask, must be positive.
+	Weight float64
+	// Divider controls the strength of the effect, must be positive.
+	Divider float64
+}
+
+// UnsharpMasking configures the unsharp masking applied when downscaling images (imgproxy Pro).
+func (i *ImgproxyURLData) UnsharpMasking(u UnsharpMaskingOptions) *ImgproxyURLData {
+	if u.Weight < 0 || u.Divider < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "unsharp masking: weight %v and divider %v must be positive", u.Weight, u.Divider))
+	}
+
+	var weight, divider string
+
+	if u.Weight > 0 {
+		weight = floatAsString(u.Weight)
+	}
+
+	if u.Divider > 0 {
+		divider = floatAsString(u.Divider)
+	}
+
+	return i.SetOption("ush", strings.TrimRight(
+		fmt.Sprintf("%s:%s:%s", u.Mode, weight, divider),
+		":",
+	))
+}
+
+// Pixelate applies the pixelate filter to the resulting image.
+// The value of size defines individual pixel size, 0 disables the filter.
+func (i *ImgproxyURLData) Pixelate(size int) *ImgproxyURLData {
+	if size < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "pixelate: size %d is negative", size))
+	}
+
+	return i.SetOption("pix", strconv.Itoa(size))
+}
+
+// Brightness adjusts the brightness of the resulting image (imgproxy Pro).
+// The value must be between -255 and 255.
+func (i *ImgproxyURLData) Brightness(brightness int) *ImgproxyURLData {
+	if brightness < -255 || brightness > 255 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "brightness: %d is out of range", brightness))
+	}
+
+	return i.SetOption("br", strconv.Itoa(brightness))
+}
+
+// Contrast adjusts the contrast of the resulting image (imgproxy Pro).
+// The value is a positive multiplier, 1 keeps the contrast unchanged.
+func (i *ImgproxyURLData) Contrast(contrast float64) *ImgproxyURLData {
+	if contrast < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "contrast: %v is negative", contrast))
+	}
+
+	return i.SetOption("co", floatAsString(contrast))
+}
+
+// Saturation adjusts the saturation of the resulting image (imgproxy Pro).
+// The value is a positive multiplier, 1 keeps the saturation unchanged.
+func (i *ImgproxyURLData) Saturation(saturation float64) *ImgproxyURLData {
+	if saturation < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "saturation: %v is negative", saturation))
+	}
+
+	return i.SetOption("sa", floatAsString(saturation))
+}
+
+// Adjust is a meta-option that sets brightness, contrast and saturation at once (imgproxy Pro).
+// The values are validated the same way as in Brightness, Contrast and Saturation.
+func (i *ImgproxyURLData) Adjust(brightness int, contrast float64, saturation float64) *ImgproxyURLData {
+	switch {
+	case brightness < -255 || brightness > 255:
+		return i.setError(errors.Wrapf(ErrInvalidOption, "adjust: brightness %d is out of range", brightness))
+	case contrast < 0:
+		return i.setError(errors.Wrapf(ErrInvalidOption, "adjust: contrast %v is negative", contrast))
+	case saturation < 0:
+		return i.setError(errors.Wrapf(ErrInvalidOption, "adjust: saturation %v is negative", saturation))
+	}
+
+	return i.SetOption("a", fmt.Sprintf(
+		"%d:%s:%s",
+		brightness,
+		floatAsString(contrast),
+		floatAsString(saturation),
+	))
+}
+
+// Monochrome converts the resulting image to monochrome (imgproxy Pro).
+// The intensity must be between 0 and 1. When color is nil, imgproxy uses its default color.
+func (i *ImgproxyURLData) Monochrome(intensity float64, color Color) *ImgproxyURLData {
+	if intensity < 0 || intensity > 1 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "monochrome: intensity %v is out of range", intensity))
+	}
+
+	if err := checkColor("monochrome", color); err != nil {
+		return i.setError(err)
+	}
+
+	monochrome := floatAsString(intensity)
+
+	if color != nil {
+		monochrome += ":" + color.GetHexOption()
+	}
+
+	return i.SetOption("mc", monochrome)
+}
+
+// Duotone converts the resulting image to duotone, mapping shadows to shadowColor
+// and highlights to highlightColor (imgproxy Pro).
+// The intensity must be between 0 and 1. When a color is nil, imgproxy uses its default color.
+func (i *ImgproxyURLData) Duotone(intensity float64, shadowColor Color, highlightColor Color) *ImgproxyURLData {
+	if intensity < 0 || intensity > 1 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "duotone: intensity %v is out of range", intensity))
+	}
+
+	for _, color := range []Color{shadowColor, highlightColor} {
+		if err := checkColor("duotone", color); err != nil {
+			return i.setError(err)
+		}
+	}
+
+	var shadow, highlight string
+
+	if shadowColor != nil {
+		shadow = shadowColor.GetHexOption()
+	}
+
+	if highlightColor != nil {
+		highlight = highlightColor.GetHexOption()
+	}
+
+	return i.SetOption("dt", strings.TrimRight(
+		fmt.Sprintf("%s:%s:%s", floatAsString(intensity), shadow, highlight),
+		":",
+	))
+}
+
 // WatermarkPosition holds a watermark position option.
 type WatermarkPosition string
 
@@ -320,7 +735,54 @@ type WatermarkOffset struct {
 	Y int
 }
 
+// WatermarkOptions holds the watermark parameters.
+// Zero values are omitted from the URL so imgproxy uses its defaults.
+type WatermarkOptions struct {
+	// Opacity of the watermark, between 0 and 1.
+	Opacity float64
+	// Position of the watermark, imgproxy uses its default position when empty.
+	Position WatermarkPosition
+	// Offset of the watermark from the position edges.
+	Offset *WatermarkOffset
+	// Scale of the watermark relative to the resulting image size, 0 keeps the original size.
+	Scale float64
+}
+
+// GetStringOption gets the watermark value as string, trimming the trailing empty arguments.
+func (w WatermarkOptions) GetStringOption() string {
+	args := []string{floatAsString(w.Opacity), string(w.Position), "", "", ""}
+
+	if w.Offset != nil {
+		args[2] = strconv.Itoa(w.Offset.X)
+		args[3] = strconv.Itoa(w.Offset.Y)
+	}
+
+	if w.Scale > 0 {
+		args[4] = floatAsString(w.Scale)
+	}
+
+	return strings.TrimRight(strings.Join(args, ":"), ":")
+}
+
+// WatermarkWithOptions places a watermark on the processed image.
+func (i *ImgproxyURLData) WatermarkWithOptions(w WatermarkOptions) *ImgproxyURLData {
+	if w.Opacity < 0 || w.Opacity > 1 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "watermark: opacity %v is out of range", w.Opacity))
+	}
+
+	if w.Scale < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "watermark: scale %v is negative", w.Scale))
+	}
+
+	return i.SetOption("wm", w.GetStringOption())
+}
+
 // Watermark places a watermark on the processed image.
+// It keeps its original serialization, which always writes the scale, even without offset,
+// so existing URLs keep their signatures.
+//
+// Deprecated: use WatermarkWithOptions, which supports fractional opacity and scale
+// and serializes the scale in the position imgproxy expects.
 func (i *ImgproxyURLData) Watermark(opacity int, position WatermarkPosition, offset *WatermarkOffset, scale int) *ImgproxyURLData {
 	var offsetStr string
 
@@ -335,6 +797,306 @@ func (i *ImgproxyURLData) Watermark(opacity int, position WatermarkPosition, off
 	)
 }
 
+// Rotate rotates the image by the given angle, which must be a multiple of 90.
+// imgproxy applies orientation options in a fixed order regardless of their order in the URL:
+// first the EXIF orientation (see AutoRotate), then Rotate, then Flip.
+func (i *ImgproxyURLData) Rotate(angle int) *ImgproxyURLData {
+	if angle%90 != 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "rotate: angle %d is not a multiple of 90", angle))
+	}
+
+	return i.SetOption("rot", strconv.Itoa(angle))
+}
+
+// AutoRotate controls whether imgproxy rotates the image according to its EXIF orientation.
+// The EXIF orientation is applied before Rotate and Flip.
+func (i *ImgproxyURLData) AutoRotate(autoRotate bool) *ImgproxyURLData {
+	return i.SetOption("ar", boolAsNumberString(autoRotate))
+}
+
+// Flip flips the image horizontally and/or vertically after it has been rotated (imgproxy Pro).
+func (i *ImgproxyURLData) Flip(horizontal bool, vertical bool) *ImgproxyURLData {
+	return i.SetOption("fl", fmt.Sprintf(
+		"%s:%s",
+		boolAsNumberString(horizontal),
+		boolAsNumberString(vertical),
+	))
+}
+
+// WatermarkURL uses the image at the given URL as watermark instead of the configured one (imgproxy Pro).
+// The URL is base64-encoded by the builder.
+func (i *ImgproxyURLData) WatermarkURL(url string) *ImgproxyURLData {
+	return i.SetOption("wmu", base64URLString(url))
+}
+
+// WatermarkText renders the given text as watermark (imgproxy Pro).
+// The text may contain Pango markup and is base64-encoded by the builder.
+func (i *ImgproxyURLData) WatermarkText(text string) *ImgproxyURLData {
+	return i.SetOption("wmt", base64URLString(text))
+}
+
+// WatermarkSize defines the desired width and height of the watermark (imgproxy Pro).
+// When set to 0, imgproxy will calculate the dimension using the watermark aspect ratio.
+func (i *ImgproxyURLData) WatermarkSize(width int, height int) *ImgproxyURLData {
+	if width < 0 || height < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "watermark size: %dx%d is negative", width, height))
+	}
+
+	return i.SetOption("wms", fmt.Sprintf("%d:%d", width, height))
+}
+
+// WatermarkRotate rotates the watermark by the given angle in degrees (imgproxy Pro).
+func (i *ImgproxyURLData) WatermarkRotate(angle int) *ImgproxyURLData {
+	return i.SetOption("wmr", strconv.Itoa(angle))
+}
+
+// WatermarkShadow adds a shadow to the watermark (imgproxy Pro).
+// The value of sigma defines the size of the mask imgproxy will use to blur the shadow.
+func (i *ImgproxyURLData) WatermarkShadow(sigma float64) *ImgproxyURLData {
+	if sigma < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "watermark shadow: sigma %v is negative", sigma))
+	}
+
+	return i.SetOption("wmsh", floatAsString(sigma))
+}
+
+// DisableAnimation uses only the first frame of animated images.
+func (i *ImgproxyURLData) DisableAnimation(disable bool) *ImgproxyURLData {
+	return i.SetOption("da", boolAsNumberString(disable))
+}
+
+// Page defines the page or frame of a multi-page document or animation to process, starting at 0 (imgproxy Pro).
+func (i *ImgproxyURLData) Page(page int) *ImgproxyURLData {
+	if page < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "page: %d is negative", page))
+	}
+
+	return i.SetOption("pg", strconv.Itoa(page))
+}
+
+// Pages defines the number of pages or frames to process, starting at Page (imgproxy Pro).
+func (i *ImgproxyURLData) Pages(pages int) *ImgproxyURLData {
+	if pages < 1 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "pages: %d is lower than 1", pages))
+	}
+
+	return i.SetOption("pgs", strconv.Itoa(pages))
+}
+
+// VideoThumbnailSecond defines the second of the video to be used as thumbnail (imgproxy Pro).
+func (i *ImgproxyURLData) VideoThumbnailSecond(second int) *ImgproxyURLData {
+	if second < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "video thumbnail second: %d is negative", second))
+	}
+
+	return i.SetOption("vts", strconv.Itoa(second))
+}
+
+// VideoThumbnailKeyframes makes imgproxy use the keyframe nearest to the requested second,
+// which is faster but less precise (imgproxy Pro).
+func (i *ImgproxyURLData) VideoThumbnailKeyframes(keyframes bool) *ImgproxyURLData {
+	return i.SetOption("vtk", boolAsNumberString(keyframes))
+}
+
+// VideoThumbnailTile holds the parameters of a video sprite sheet.
+type VideoThumbnailTile struct {
+	// Step in seconds between frames. When negative, imgproxy spreads the frames over the whole video.
+	Step float64
+	// Columns and Rows of the sprite sheet, both at least 1.
+	Columns int
+	Rows    int
+	// TileWidth and TileHeight of each frame in the sprite sheet.
+	TileWidth  int
+	TileHeight int
+	// ExtendTile extends each frame to the exact tile size.
+	ExtendTile bool
+	// Trim removes the empty tiles at the end of the sprite sheet.
+	Trim bool
+	// Fill resizes the frames to fill the tile, cropping projecting parts.
+	Fill bool
+}
+
+// GetStringOption gets the video thumbnail tile value as string.
+func (v VideoThumbnailTile) GetStringOption() string {
+	return fmt.Sprintf(
+		"%s:%d:%d:%d:%d:%s:%s:%s",
+		floatAsString(v.Step),
+		v.Columns, v.Rows,
+		v.TileWidth, v.TileHeight,
+		boolAsNumberString(v.ExtendTile),
+		boolAsNumberString(v.Trim),
+		boolAsNumberString(v.Fill),
+	)
+}
+
+// TileCount returns the number of tiles in the sprite sheet.
+func (v VideoThumbnailTile) TileCount() int {
+	return v.Columns * v.Rows
+}
+
+// SpriteSize returns the size of the sprite sheet.
+// The size is exact only when ExtendTile is set, otherwise frames can be smaller than a tile.
+func (v VideoThumbnailTile) SpriteSize() (width int, height int) {
+	return v.Columns * v.TileWidth, v.Rows * v.TileHeight
+}
+
+// TilePosition returns the top-left coordinates of the tile at the given index in the sprite sheet.
+// Tiles are laid out row by row, starting at index 0.
+func (v VideoThumbnailTile) TilePosition(index int) (x int, y int) {
+	if v.Columns < 1 {
+		return 0, 0
+	}
+
+	return (index % v.Columns) * v.TileWidth, (index / v.Columns) * v.TileHeight
+}
+
+// VideoThumbnailTile generates a sprite sheet of video frames (imgproxy Pro).
+func (i *ImgproxyURLData) VideoThumbnailTile(v VideoThumbnailTile) *ImgproxyURLData {
+	if v.Columns < 1 || v.Rows < 1 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "video thumbnail tile: %dx%d grid is empty", v.Columns, v.Rows))
+	}
+
+	if v.TileWidth < 0 || v.TileHeight < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "video thumbnail tile: %dx%d tile is negative", v.TileWidth, v.TileHeight))
+	}
+
+	return i.SetOption("vtt", v.GetStringOption())
+}
+
+// VideoThumbnailAnimation holds the parameters of an animated video thumbnail.
+type VideoThumbnailAnimation struct {
+	// Step in seconds between frames. When negative, imgproxy spreads the frames over the whole video.
+	Step float64
+	// Delay between animation frames in milliseconds.
+	Delay int
+	// Frames is the number of animation frames.
+	Frames int
+	// FrameWidth and FrameHeight of the animation.
+	FrameWidth  int
+	FrameHeight int
+	// ExtendFrame extends each frame to the exact frame size.
+	ExtendFrame bool
+	// Trim removes the empty frames at the end of the animation.
+	Trim bool
+	// Fill resizes the frames to fill the frame size, cropping projecting parts.
+	Fill bool
+}
+
+// GetStringOption gets the video thumbnail animation value as string.
+func (v VideoThumbnailAnimation) GetStringOption() string {
+	return fmt.Sprintf(
+		"%s:%d:%d:%d:%d:%s:%s:%s",
+		floatAsString(v.Step),
+		v.Delay, v.Frames,
+		v.FrameWidth, v.FrameHeight,
+		boolAsNumberString(v.ExtendFrame),
+		boolAsNumberString(v.Trim),
+		boolAsNumberString(v.Fill),
+	)
+}
+
+// VideoThumbnailAnimation generates an animated thumbnail of a video (imgproxy Pro).
+func (i *ImgproxyURLData) VideoThumbnailAnimation(v VideoThumbnailAnimation) *ImgproxyURLData {
+	if v.Delay < 0 || v.Frames < 1 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "video thumbnail animation: %d frames with %dms delay", v.Frames, v.Delay))
+	}
+
+	if v.FrameWidth < 0 || v.FrameHeight < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "video thumbnail animation: %dx%d frame is negative", v.FrameWidth, v.FrameHeight))
+	}
+
+	return i.SetOption("vta", v.GetStringOption())
+}
+
+// Metadata holds the metadata and color profile options.
+// Nil fields are omitted from the URL so imgproxy uses its configured defaults.
+type Metadata struct {
+	// StripMetadata removes the metadata (EXIF, IPTC, XMP, ...) from the resulting image.
+	StripMetadata *bool
+	// KeepCopyright keeps the copyright information when stripping the metadata.
+	KeepCopyright *bool
+	// StripColorProfile converts the image to sRGB and removes its ICC profile.
+	StripColorProfile *bool
+	// EnforceThumbnail uses the embedded thumbnail of the source image when present.
+	EnforceThumbnail *bool
+	// DPI sets the resulting image DPI metadata, 0 leaves it unset (imgproxy Pro).
+	DPI int
+}
+
+// Metadata sets the metadata and color profile options that are explicitly set.
+func (i *ImgproxyURLData) Metadata(m Metadata) *ImgproxyURLData {
+	if m.DPI < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "dpi: %d is negative", m.DPI))
+	}
+
+	if m.StripMetadata != nil {
+		i.SetOption("sm", boolAsNumberString(*m.StripMetadata))
+	}
+
+	if m.KeepCopyright != nil {
+		i.SetOption("kcr", boolAsNumberString(*m.KeepCopyright))
+	}
+
+	if m.StripColorProfile != nil {
+		i.SetOption("scp", boolAsNumberString(*m.StripColorProfile))
+	}
+
+	if m.EnforceThumbnail != nil {
+		i.SetOption("eth", boolAsNumberString(*m.EnforceThumbnail))
+	}
+
+	if m.DPI > 0 {
+		i.SetOption("dpi", strconv.Itoa(m.DPI))
+	}
+
+	return i
+}
+
+// Limits holds the per URL source safety limits, overriding the server configuration (imgproxy Pro).
+// Zero values are omitted from the URL so imgproxy uses its configured limits.
+type Limits struct {
+	// MaxSrcResolution is the maximum resolution of the source image, in megapixels.
+	MaxSrcResolution float64
+	// MaxSrcFileSize is the maximum size of the source image file, in bytes.
+	MaxSrcFileSize int64
+	// MaxAnimationFrames is the maximum number of frames of an animated source image.
+	MaxAnimationFrames int
+	// MaxAnimationFrameResolution is the maximum resolution of a single animation frame, in megapixels.
+	MaxAnimationFrameResolution float64
+	// MaxResultDimension is the maximum width or height of the resulting image, in pixels.
+	MaxResultDimension int
+}
+
+// Limits sets the source safety limits that are not zero.
+func (i *ImgproxyURLData) Limits(l Limits) *ImgproxyURLData {
+	if l.MaxSrcResolution < 0 || l.MaxSrcFileSize < 0 || l.MaxAnimationFrames < 0 ||
+		l.MaxAnimationFrameResolution < 0 || l.MaxResultDimension < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "limits: %+v has negative values", l))
+	}
+
+	if l.MaxSrcResolution > 0 {
+		i.SetOption("msr", floatAsString(l.MaxSrcResolution))
+	}
+
+	if l.MaxSrcFileSize > 0 {
+		i.SetOption("msfs", strconv.FormatInt(l.MaxSrcFileSize, 10))
+	}
+
+	if l.MaxAnimationFrames > 0 {
+		i.SetOption("maf", strconv.Itoa(l.MaxAnimationFrames))
+	}
+
+	if l.MaxAnimationFrameResolution > 0 {
+		i.SetOption("mafr", floatAsString(l.MaxAnimationFrameResolution))
+	}
+
+	if l.MaxResultDimension > 0 {
+		i.SetOption("mrd", strconv.Itoa(l.MaxResultDimension))
+	}
+
+	return i
+}
+
 // Preset defines a list of presets to be used by imgproxy.
 func (i *ImgproxyURLData) Preset(presets ...string) *ImgproxyURLData {
 	return i.SetOption("pr", strings.Join(presets, ":"))
@@ -347,6 +1109,95 @@ func (i *ImgproxyURLData) CacheBuster(buster string) *ImgproxyURLData {
 	return i.SetOption("cb", buster)
 }
 
+// Filename defines the filename of the resulting image in the Content-Disposition header.
+// When encode is true, the filename is base64-encoded so it may contain any character.
+// Otherwise it is percent-encoded, which imgproxy decodes, and must not contain : or /.
+func (i *ImgproxyURLData) Filename(filename string, encode bool) *ImgproxyURLData {
+	if encode {
+		return i.SetOption("fn", base64URLString(filename)+":1")
+	}
+
+	if strings.ContainsAny(filename, ":/") {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "filename: %q must be encoded", filename))
+	}
+
+	return i.SetOption("fn", url.PathEscape(filename))
+}
+
+// ReturnAttachment makes imgproxy return the image with an attachment Content-Disposition header,
+// so browsers download it instead of displaying it.
+func (i *ImgproxyURLData) ReturnAttachment(attachment bool) *ImgproxyURLData {
+	return i.SetOption("att", boolAsNumberString(attachment))
+}
+
+// Expires makes imgproxy return 404 Not Found for the URL after the given time.
+func (i *ImgproxyURLData) Expires(t time.Time) *ImgproxyURLData {
+	return i.SetOption("exp", strconv.FormatInt(t.Unix(), 10))
+}
+
+// ExpiresIn makes imgproxy return 404 Not Found for the URL once the given duration has elapsed,
+// relative to the configured clock.
+func (i *ImgproxyURLData) ExpiresIn(d time.Duration) *ImgproxyURLData {
+	return i.Expires(i.cfg.Clock().Add(d))
+}
+
+// FormatEnum holds an image format option value.
+type FormatEnum string
+
+// FormatEnum constants.
+const (
+	FormatEnumPNG  = FormatEnum("png")
+	FormatEnumJPEG = FormatEnum("jpg")
+	FormatEnumWebP = FormatEnum("webp")
+	FormatEnumAVIF = FormatEnum("avif")
+	FormatEnumJXL  = FormatEnum("jxl")
+	FormatEnumGIF  = FormatEnum("gif")
+	FormatEnumICO  = FormatEnum("ico")
+	FormatEnumSVG  = FormatEnum("svg")
+	FormatEnumHEIC = FormatEnum("heic")
+	FormatEnumBMP  = FormatEnum("bmp")
+	FormatEnumTIFF = FormatEnum("tiff")
+	FormatEnumMP4  = FormatEnum("mp4")
+	FormatEnumPDF  = FormatEnum("pdf")
+)
+
+// SkipProcessing makes imgproxy return the source image untouched when it has one of the given formats.
+// At least one format is required.
+func (i *ImgproxyURLData) SkipProcessing(formats ...FormatEnum) *ImgproxyURLData {
+	if len(formats) == 0 {
+		return i.setError(errors.Wrap(ErrInvalidOption, "skip processing: no formats"))
+	}
+
+	extensions := make([]string, len(formats))
+	for j, format := range formats {
+		extensions[j] = string(format)
+	}
+
+	return i.SetOption("skp", strings.Join(extensions, ":"))
+}
+
+// Raw makes imgproxy stream the source image without processing it.
+// Only the options that don't require processing, like Filename or ReturnAttachment, are applied.
+func (i *ImgproxyURLData) Raw(raw bool) *ImgproxyURLData {
+	return i.SetOption("raw", boolAsNumberString(raw))
+}
+
+// FallbackImageURL defines the image imgproxy returns when the source image can't be fetched (imgproxy Pro).
+// The URL is base64-encoded by the builder.
+func (i *ImgproxyURLData) FallbackImageURL(url string) *ImgproxyURLData {
+	return i.SetOption("fiu", base64URLString(url))
+}
+
+// MaxBytes limits the resulting file size, in bytes.
+// imgproxy degrades the quality of the image until it fits, which only applies to JPEG, WebP and AVIF.
+func (i *ImgproxyURLData) MaxBytes(bytes int) *ImgproxyURLData {
+	if bytes < 0 {
+		return i.setError(errors.Wrapf(ErrInvalidOption, "max bytes: %d is negative", bytes))
+	}
+
+	return i.SetOption("mb", strconv.Itoa(bytes))
+}
+
 // Format specifies the resulting image format. Alias for the extension part of the URL.
 func (i *ImgproxyURLData) Format(extension string) *ImgproxyURLData {
 	return i.SetOption("f", extension)
@@ -363,8 +1214,18 @@ func (i *ImgproxyURLData) Crop(width int, height int, gravity GravitySetter) *Im
 	return i.SetOption("c", crop)
 }
 
-// SetOption sets an option on the URL.
+// SetOption sets an option on the URL, in the current pipeline.
 func (i *ImgproxyURLData) SetOption(key, value string) *ImgproxyURLData {
-	i.Options[key] = value
+	pipelines := i.currentPipelines()
+	pipelines[len(pipelines)-1].set(key, value)
+	return i
+}
+
+// setError records the first invalid option, which is returned by Generate.
+func (i *ImgproxyURLData) setError(err error) *ImgproxyURLData {
+	if i.err == nil {
+		i.err = err
+	}
+
 	return i
 }
@@ -1,5 +1,10 @@
 package imgproxy
 
+import (
+	"encoding/base64"
+	"strconv"
+)
+
 func boolAsNumberString(i bool) string {
 	if i {
 		return "1"
@@ -7,3 +12,16 @@ func boolAsNumberString(i bool) string {
 
 	return "0"
 }
+
+func floatAsString(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func base64URLString(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// Bool returns a pointer to the given bool, for optional option fields.
+func Bool(b bool) *bool {
+	return &b
+}